@@ -19,31 +19,47 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/sethvargo/go-password/password"
+	"github.com/samber/lo"
+
+	"github.com/nametaginc/cli/directory/pwgen"
 )
 
 // PasswordPolicy is the representation of the policy in the system
 type PasswordPolicy struct {
-	MinPasswordLength *int `json:"MinPasswordLength,omitempty"`
+	MinPasswordLength *int  `json:"MinPasswordLength,omitempty"`
+	ComplexityEnabled *bool `json:"ComplexityEnabled,omitempty"`
 }
 
 // PasswordArgs is the args to password functions
 type PasswordArgs struct {
 	UserImmutableID string
+	Options         pwgen.Options
 }
 
 // AssignTemporaryPassword will reset a users password with a temporary password that will be required to reset on login.
 // There is no native way to have AD generate and reset with a password. It must be supplied by the admin during reset time.
 // We figure out what password policy applies to the user and make a password according to the policy.
-// We always operate on the assumption that `ComplexityEnabled` is true.
-// This means the password has to have at least 3 out of Uppercase, Lowercase, Number, Special Characters.
+// If `ComplexityEnabled` is true, the password has to have at least 3 out of Uppercase, Lowercase,
+// Number, Special Characters, and must not contain the account name or parts of the display name.
 func AssignTemporaryPassword(s Client, args PasswordArgs) (*string, error) {
 	passwordPolicy, err := GetPasswordPolicy(s, args.UserImmutableID)
 	if err != nil {
 		return nil, fmt.Errorf("could not get password policy: %w", err)
 	}
 
-	res, err := password.Generate(*passwordPolicy.MinPasswordLength, 1, 1, false, false)
+	policy := pwgen.Policy{MinLength: lo.FromPtr(passwordPolicy.MinPasswordLength)}
+	if lo.FromPtrOr(passwordPolicy.ComplexityEnabled, true) {
+		users, err := GetADUser(s, GetADUserArgs{Identity: args.UserImmutableID})
+		if err != nil {
+			return nil, err
+		}
+		policy.MinClasses = 3
+		for _, user := range *users {
+			policy.Forbidden = append(policy.Forbidden, pwgen.ADForbidden(user.SamAccountName, user.DisplayName)...)
+		}
+	}
+
+	res, err := pwgen.Generate(policy, args.Options)
 	if err != nil {
 		return nil, err
 	}
@@ -62,7 +78,7 @@ func AssignTemporaryPassword(s Client, args PasswordArgs) (*string, error) {
 // If there are no groups the user is a member one then the default password policy is retrieved.
 func GetPasswordPolicy(s Client, immutableID string) (*PasswordPolicy, error) {
 	escapedID := strings.ReplaceAll(immutableID, "'", "''")
-	cmdString := fmt.Sprintf("$userDN=(Get-ADUser -Identity '%s').DistinguishedName;$groupDNs=(Get-ADUser -Identity '%s' -Properties MemberOf).MemberOf;$appliesToList=$groupDNs+$userDN;Get-ADFineGrainedPasswordPolicy -Filter * | Where-Object {$_.AppliesTo -match ($appliesToList -join '|')} | Sort-Object Precedence | Select-Object -First 1 | Select-Object MinPasswordLength | ConvertTo-Json", escapedID, escapedID)
+	cmdString := fmt.Sprintf("$userDN=(Get-ADUser -Identity '%s').DistinguishedName;$groupDNs=(Get-ADUser -Identity '%s' -Properties MemberOf).MemberOf;$appliesToList=$groupDNs+$userDN;Get-ADFineGrainedPasswordPolicy -Filter * | Where-Object {$_.AppliesTo -match ($appliesToList -join '|')} | Sort-Object Precedence | Select-Object -First 1 | Select-Object MinPasswordLength,ComplexityEnabled | ConvertTo-Json", escapedID, escapedID)
 	stdout, err := s.Execute(cmdString)
 	if err != nil {
 		return nil, err
@@ -78,7 +94,7 @@ func GetPasswordPolicy(s Client, immutableID string) (*PasswordPolicy, error) {
 	}
 
 	// If we don't find any Fine Grained Password Policy for the user, we use the default one.
-	cmdString = "Get-ADDefaultDomainPasswordPolicy | Select-Object -Property MinPasswordLength | ConvertTo-Json"
+	cmdString = "Get-ADDefaultDomainPasswordPolicy | Select-Object -Property MinPasswordLength,ComplexityEnabled | ConvertTo-Json"
 	stdout, err = s.Execute(cmdString)
 	if err != nil {
 		return nil, err
@@ -40,6 +40,7 @@ type User struct {
 	SamAccountName    string      `json:"SamAccountName"`
 	DistinguishedName string      `json:"DistinguishedName"`
 	Name              string      `json:"Name"`
+	DisplayName       string      `json:"DisplayName"`
 	EmailAddress      string      `json:"EmailAddress"`
 	EmployeeID        interface{} `json:"EmployeeID"`
 	ObjectGUID        string      `json:"ObjectGUID"`
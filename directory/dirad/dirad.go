@@ -22,11 +22,15 @@ import (
 
 	"github.com/nametaginc/cli/diragentapi"
 	"github.com/nametaginc/cli/directory/dirad/adclient"
+	"github.com/nametaginc/cli/directory/pwgen"
 )
 
 // Provider represents the directory provider
 type Provider struct {
 	_client adclient.Client
+
+	// PasswordGeneration configures how temporary passwords are generated.
+	PasswordGeneration pwgen.Options
 }
 
 func (p *Provider) client() (adclient.Client, error) {
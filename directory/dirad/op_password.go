@@ -33,7 +33,10 @@ func (p *Provider) performOperationGetTemporaryPassword(ctx context.Context, req
 		return &diragentapi.DirAgentPerformOperationResponse{}, nil
 	}
 
-	tempPassword, err := adclient.AssignTemporaryPassword(client, adclient.PasswordArgs{UserImmutableID: req.AccountImmutableID})
+	tempPassword, err := adclient.AssignTemporaryPassword(client, adclient.PasswordArgs{
+		UserImmutableID: req.AccountImmutableID,
+		Options:         p.PasswordGeneration,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -50,6 +50,10 @@ type Provider struct {
 	NameAttribute      string
 	BirthDateAttribute string
 	MFAResetFlowUUID   string
+
+	// RefreshCredentials is called when authentik rejects Token, and may
+	// update it.
+	RefreshCredentials directory.RefreshCredentialsFunc
 }
 
 // Configure returns static information about the integration.
@@ -192,41 +196,26 @@ func (p *Provider) doJSON(ctx context.Context, method string, path string, query
 		endpointURL.RawQuery = query.Encode()
 	}
 
-	var body io.Reader
+	var body []byte
 	if payload != nil {
 		buf := &bytes.Buffer{}
 		if err := json.NewEncoder(buf).Encode(payload); err != nil {
 			return fmt.Errorf("authentik: encode request: %w", err)
 		}
-		body = buf
+		body = buf.Bytes()
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, endpointURL.String(), body)
+	resp, err := p.do(ctx, client, method, endpointURL.String(), body)
 	if err != nil {
 		return err
 	}
-	token := strings.TrimSpace(p.Token)
-	if strings.HasPrefix(strings.ToLower(token), "bearer ") {
-		token = strings.TrimSpace(token[len("bearer "):])
-	}
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", "application/json")
-	if payload != nil {
-		req.Header.Set("Content-Type", "application/json")
-	}
-	for key, values := range p.ExtraHeaders {
-		if len(req.Header.Values(key)) > 0 {
-			continue
-		}
-		for _, value := range values {
-			req.Header.Add(key, value)
+	if resp.StatusCode == http.StatusUnauthorized && p.RefreshCredentials.Refresh() {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if resp, err = p.do(ctx, client, method, endpointURL.String(), body); err != nil {
+			return err
 		}
 	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode >= http.StatusBadRequest {
@@ -244,6 +233,36 @@ func (p *Provider) doJSON(ctx context.Context, method string, path string, query
 	return nil
 }
 
+// do sends a request with the authentication and extra headers.
+func (p *Provider) do(ctx context.Context, client *http.Client, method string, endpoint string, body []byte) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	token := strings.TrimSpace(p.Token)
+	if strings.HasPrefix(strings.ToLower(token), "bearer ") {
+		token = strings.TrimSpace(token[len("bearer "):])
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, values := range p.ExtraHeaders {
+		if len(req.Header.Values(key)) > 0 {
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	return client.Do(req)
+}
+
 func (p *Provider) parseError(resp *http.Response) error {
 	body, _ := io.ReadAll(resp.Body)
 	message := strings.TrimSpace(string(body))
@@ -0,0 +1,194 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirldap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/directory/pwgen"
+	"github.com/nametaginc/cli/internal/config"
+)
+
+// Active Directory differs from other LDAP servers in how passwords are set
+// and how password policies are found, so when the activedirectory schema
+// preset is selected, the provider talks to domain controllers directly
+// rather than through the ppolicy overlay attributes.
+//
+// Domain controllers only accept password changes over an encrypted
+// connection, so use ldaps:// or StartTLS.
+
+const schemaPresetActiveDirectory = "activedirectory"
+
+// domainPasswordComplex is the pwdProperties flag that enables complexity
+// requirements in the domain password policy.
+const domainPasswordComplex = 1
+
+// isActiveDirectory returns true if the directory is Active Directory.
+func isActiveDirectory(schema *config.LDAPSchema) bool {
+	return schema.Preset == schemaPresetActiveDirectory
+}
+
+// assignADTemporaryPassword resets the password of a user to a generated
+// one that satisfies the password policy that applies to them, and requires
+// them to change it on next logon.
+func (p *Provider) assignADTemporaryPassword(client Client, schema *config.LDAPSchema, immutableID string) (*diragentapi.DirAgentPerformOperationResponse, error) {
+	entry, err := p.findUser(client, schema, immutableID, []string{
+		"msDS-ResultantPSO", // The fine-grained password policy that applies, if any
+		"sAMAccountName",
+		"displayName",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := p.adPasswordPolicy(client, entry)
+	if err != nil {
+		return nil, fmt.Errorf("could not get password policy: %w", err)
+	}
+
+	tempPassword, err := pwgen.Generate(policy, pwgen.Options(p.Config.PasswordGeneration))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate password: %w", err)
+	}
+
+	modify := ldap.NewModifyRequest(entry.DN, nil)
+	modify.Replace("unicodePwd", []string{encodeUnicodePwd(tempPassword)})
+
+	// A pwdLastSet of zero requires the user to change the password at next logon
+	modify.Replace("pwdLastSet", []string{"0"})
+
+	if err := client.Modify(modify); err != nil {
+		return nil, fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	return &diragentapi.DirAgentPerformOperationResponse{
+		TemporaryPassword: &tempPassword,
+	}, nil
+}
+
+// adPasswordPolicy returns the password policy for a user entry, which was
+// fetched with msDS-ResultantPSO, sAMAccountName and displayName. The
+// resultant PSO is the fine-grained password policy with the lowest
+// precedence that applies to the user directly or through a group. If there
+// isn't one, the domain policy applies.
+func (p *Provider) adPasswordPolicy(client Client, entry *ldap.Entry) (pwgen.Policy, error) {
+	var minLength int
+	var complexity bool
+	if psoDN := entry.GetAttributeValue("msDS-ResultantPSO"); psoDN != "" {
+		pso, err := readEntry(client, psoDN, "msDS-MinimumPasswordLength", "msDS-PasswordComplexityEnabled")
+		if err != nil {
+			return pwgen.Policy{}, err
+		}
+		if minLength, err = intAttributeValue(pso, "msDS-MinimumPasswordLength"); err != nil {
+			return pwgen.Policy{}, err
+		}
+		complexity = strings.EqualFold(pso.GetAttributeValue("msDS-PasswordComplexityEnabled"), "TRUE")
+	} else {
+		domainDN, err := p.defaultNamingContext(client)
+		if err != nil {
+			return pwgen.Policy{}, err
+		}
+		if domainDN == "" {
+			// assume the defaults of a new domain
+			minLength, complexity = 7, true
+		} else {
+			domain, err := readEntry(client, domainDN, "minPwdLength", "pwdProperties")
+			if err != nil {
+				return pwgen.Policy{}, err
+			}
+			if minLength, err = intAttributeValue(domain, "minPwdLength"); err != nil {
+				return pwgen.Policy{}, err
+			}
+			properties, err := intAttributeValue(domain, "pwdProperties")
+			if err != nil {
+				return pwgen.Policy{}, err
+			}
+			complexity = properties&domainPasswordComplex != 0
+		}
+	}
+
+	policy := pwgen.Policy{MinLength: minLength}
+	if complexity {
+		// Complexity requires three of the four character classes, and
+		// forbids the account name and parts of the display name.
+		policy.MinClasses = 3
+		policy.Forbidden = pwgen.ADForbidden(
+			entry.GetAttributeValue("sAMAccountName"),
+			entry.GetAttributeValue("displayName"))
+	}
+	return policy, nil
+}
+
+// defaultNamingContext returns the DN of the domain the server belongs to,
+// or an empty string if the server doesn't say.
+func (p *Provider) defaultNamingContext(client Client) (string, error) {
+	result, err := client.Search(ldap.NewSearchRequest(
+		"", // Empty DN for Root DSE
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		"(objectClass=*)",
+		[]string{"defaultNamingContext"},
+		nil,
+	))
+	if err != nil {
+		return "", err
+	}
+	if len(result.Entries) == 0 {
+		return "", nil
+	}
+	return result.Entries[0].GetAttributeValue("defaultNamingContext"), nil
+}
+
+// readEntry reads attributes of the entry at dn.
+func readEntry(client Client, dn string, attributes ...string) (*ldap.Entry, error) {
+	result, err := client.Search(ldap.NewSearchRequest(
+		dn,
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		"(objectClass=*)",
+		attributes,
+		nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", dn, err)
+	}
+	if len(result.Entries) != 1 {
+		return nil, fmt.Errorf("expected exactly one entry for %s, got %d", dn, len(result.Entries))
+	}
+	return result.Entries[0], nil
+}
+
+// encodeUnicodePwd encodes a password for the unicodePwd attribute, which
+// takes the password surrounded by double quotes, in UTF-16LE.
+func encodeUnicodePwd(pw string) string {
+	codes := utf16.Encode([]rune(`"` + pw + `"`))
+	buf := make([]byte, 2*len(codes))
+	for i, c := range codes {
+		binary.LittleEndian.PutUint16(buf[2*i:], c)
+	}
+	return string(buf)
+}
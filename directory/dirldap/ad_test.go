@@ -0,0 +1,301 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirldap
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/samber/lo"
+
+	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/directory"
+	"github.com/nametaginc/cli/internal/config"
+)
+
+// The GUIDs of the test entries, in string form and as stored in objectGUID.
+// aliceGUID has bytes that are special in filters.
+const (
+	aliceGUID  = "5c2a2928-0100-0302-0405-060708090a0b"
+	bobGUID    = "01234567-89ab-cdef-0123-456789abcdef"
+	adminsGUID = "11111111-2222-3333-4444-555555555555"
+	staffGUID  = "66666666-7777-8888-9999-aaaaaaaaaaaa"
+)
+
+func rawGUID(t *testing.T, id string) string {
+	raw, err := parseGUID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+// newADServer returns a server with a small Active Directory domain: alice,
+// who is locked out and a member of Admins, which is a member of Staff, and
+// bob, to whom a fine-grained password policy applies.
+func newADServer(t *testing.T) *testServer {
+	return newTestServer(t,
+		testEntry("", map[string][]string{
+			"objectClass":          {"top"},
+			"defaultNamingContext": {"dc=example,dc=com"},
+		}),
+		testEntry("dc=example,dc=com", map[string][]string{
+			"objectClass":   {"top", "domain", "domainDNS"},
+			"minPwdLength":  {"12"},
+			"pwdProperties": {"1"},
+		}),
+		testEntry("cn=Users,dc=example,dc=com", map[string][]string{
+			"objectClass": {"top", "container"},
+		}),
+		testEntry("cn=Alice Example,cn=Users,dc=example,dc=com", map[string][]string{
+			"objectClass":       {"top", "person", "organizationalPerson", "user"},
+			"objectCategory":    {"person"},
+			"objectGUID":        {rawGUID(t, aliceGUID)},
+			"sAMAccountName":    {"alice"},
+			"userPrincipalName": {"alice@example.com"},
+			"mail":              {"alice@example.com"},
+			"displayName":       {"Alice Example"},
+			"whenChanged":       {"20260301120000.0Z"},
+			"memberOf":          {"CN=Admins,CN=Users,DC=example,DC=com"},
+			"lockoutTime":       {"134000000000000000"},
+		}),
+		testEntry("cn=Bob Example,cn=Users,dc=example,dc=com", map[string][]string{
+			"objectClass":       {"top", "person", "organizationalPerson", "user"},
+			"objectCategory":    {"person"},
+			"objectGUID":        {rawGUID(t, bobGUID)},
+			"sAMAccountName":    {"bob"},
+			"userPrincipalName": {"bob@example.com"},
+			"displayName":       {"Bob Example"},
+			"whenChanged":       {"20260101120000.0Z"},
+			"msDS-ResultantPSO": {"cn=Strict,cn=Password Settings Container,cn=System,dc=example,dc=com"},
+			"lockoutTime":       {"0"},
+		}),
+		testEntry("cn=Admins,cn=Users,dc=example,dc=com", map[string][]string{
+			"objectClass": {"top", "group"},
+			"objectGUID":  {rawGUID(t, adminsGUID)},
+			"cn":          {"Admins"},
+			"memberOf":    {"cn=Staff,cn=Users,dc=example,dc=com"},
+		}),
+		testEntry("cn=Staff,cn=Users,dc=example,dc=com", map[string][]string{
+			"objectClass": {"top", "group"},
+			"objectGUID":  {rawGUID(t, staffGUID)},
+			"cn":          {"Staff"},
+		}),
+		testEntry("cn=Strict,cn=Password Settings Container,cn=System,dc=example,dc=com", map[string][]string{
+			"objectClass":                    {"top", "msDS-PasswordSettings"},
+			"msDS-MinimumPasswordLength":     {"24"},
+			"msDS-PasswordComplexityEnabled": {"TRUE"},
+		}),
+	)
+}
+
+func newADProvider(t *testing.T, server *testServer) *Provider {
+	return newTestProvider(t, server, 1, config.LDAPSchema{Preset: schemaPresetActiveDirectory})
+}
+
+func TestGUID(t *testing.T) {
+	for _, test := range []struct {
+		id  string
+		raw []byte
+	}{
+		{bobGUID, []byte{0x67, 0x45, 0x23, 0x01, 0xab, 0x89, 0xef, 0xcd, 0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}},
+		{aliceGUID, []byte{0x28, 0x29, 0x2a, 0x5c, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b}},
+	} {
+		if got := formatGUID(test.raw); got != test.id {
+			t.Errorf("formatGUID(%x) = %q, want %q", test.raw, got, test.id)
+		}
+		raw, err := parseGUID(test.id)
+		if err != nil || !slices.Equal(raw, test.raw) {
+			t.Errorf("parseGUID(%q) = %x, %v, want %x", test.id, raw, err, test.raw)
+		}
+		if raw, err := parseGUID(strings.ToUpper(test.id)); err != nil || !slices.Equal(raw, test.raw) {
+			t.Errorf("parseGUID(%q) = %x, %v, want %x", strings.ToUpper(test.id), raw, err, test.raw)
+		}
+	}
+
+	for _, id := range []string{"", "not-a-guid", "01234567-89ab-cdef-0123-456789abcd"} {
+		if _, err := parseGUID(id); err == nil {
+			t.Errorf("parseGUID(%q) succeeded, want an error", id)
+		}
+	}
+}
+
+func TestADGetAccountByObjectGUID(t *testing.T) {
+	p := newADProvider(t, newADServer(t))
+
+	for _, test := range []struct {
+		ref  diragentapi.DirAgentAccountRef
+		want []string
+	}{
+		{diragentapi.DirAgentAccountRef{ImmutableID: lo.ToPtr(aliceGUID)}, []string{aliceGUID}},
+		{diragentapi.DirAgentAccountRef{ImmutableID: lo.ToPtr(strings.ToUpper(bobGUID))}, []string{bobGUID}},
+		{diragentapi.DirAgentAccountRef{ImmutableID: lo.ToPtr(adminsGUID)}, nil}, // a group, not a user
+		{diragentapi.DirAgentAccountRef{ImmutableID: lo.ToPtr("alice")}, nil},
+		{diragentapi.DirAgentAccountRef{ID: lo.ToPtr("bob@example.com")}, []string{bobGUID}},
+		{diragentapi.DirAgentAccountRef{ImmutableID: lo.ToPtr("alice"), ID: lo.ToPtr("alice")}, []string{aliceGUID}},
+	} {
+		resp, err := p.GetAccount(context.Background(), diragentapi.DirAgentGetAccountRequest{Ref: test.ref})
+		if err != nil {
+			t.Fatalf("%s: %v", refString(test.ref), err)
+		}
+		got := lo.Map(resp.Accounts, func(account diragentapi.DirAgentAccount, _ int) string { return account.ImmutableID })
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: got %v, want %v", refString(test.ref), got, test.want)
+		}
+	}
+}
+
+func TestADListAccountsUpdatedAfter(t *testing.T) {
+	p := newADProvider(t, newADServer(t))
+
+	all := listAllAccounts(t, p, diragentapi.DirAgentListAccountsRequest{}, nil)
+	if want := []string{aliceGUID, bobGUID}; !slices.Equal(all, want) {
+		t.Errorf("full sync: got %v, want %v", all, want)
+	}
+
+	// whenChanged has its own GeneralizedTime layout, with a fraction
+	updatedAfter := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	changed := listAllAccounts(t, p, diragentapi.DirAgentListAccountsRequest{UpdatedAfter: &updatedAfter}, nil)
+	if want := []string{aliceGUID}; !slices.Equal(changed, want) {
+		t.Errorf("incremental sync: got %v, want %v", changed, want)
+	}
+
+	resp, err := p.ListAccounts(context.Background(), diragentapi.DirAgentListAccountsRequest{UpdatedAfter: &updatedAfter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	account := resp.Accounts[0]
+	if want := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC); account.UpdatedAt == nil || !account.UpdatedAt.Equal(want) {
+		t.Errorf("UpdatedAt = %v, want %s", account.UpdatedAt, want)
+	}
+	if want := []string{"alice@example.com", "alice"}; !slices.Equal(account.IDs, want) {
+		t.Errorf("IDs = %v, want %v", account.IDs, want)
+	}
+	if account.Name != "Alice Example" {
+		t.Errorf("Name = %q, want the display name", account.Name)
+	}
+}
+
+func TestADMemberOf(t *testing.T) {
+	for _, test := range []struct {
+		nested bool
+		want   []diragentapi.DirAgentGroup
+	}{
+		{false, []diragentapi.DirAgentGroup{
+			{ImmutableID: adminsGUID, Name: "Admins", Kind: "group"},
+		}},
+		{true, []diragentapi.DirAgentGroup{
+			{ImmutableID: adminsGUID, Name: "Admins", Kind: "group"},
+			{ImmutableID: staffGUID, Name: "Staff", Kind: "group"},
+		}},
+	} {
+		p := newTestProvider(t, newADServer(t), 1, config.LDAPSchema{
+			Preset:       schemaPresetActiveDirectory,
+			NestedGroups: test.nested,
+		})
+		resp, err := p.GetAccount(context.Background(), diragentapi.DirAgentGetAccountRequest{
+			Ref: diragentapi.DirAgentAccountRef{ID: lo.ToPtr("alice")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Accounts) != 1 || resp.Accounts[0].Groups == nil {
+			t.Fatalf("got %+v, want alice with groups", resp.Accounts)
+		}
+		if got := *resp.Accounts[0].Groups; !slices.Equal(got, test.want) {
+			t.Errorf("nested %t: got groups %v, want %v", test.nested, got, test.want)
+		}
+	}
+}
+
+func TestADTemporaryPassword(t *testing.T) {
+	for _, test := range []struct {
+		user           string
+		dn             string
+		immutableID    string
+		minLength      int
+		forbiddenParts []string
+	}{
+		{"alice", "cn=Alice Example,cn=Users,dc=example,dc=com", aliceGUID, 12, []string{"alice", "Alice", "Example"}}, // domain policy
+		{"bob", "cn=Bob Example,cn=Users,dc=example,dc=com", bobGUID, 24, []string{"bob", "Bob Example", "Example"}},   // msDS-ResultantPSO
+	} {
+		server := newADServer(t)
+		p := newADProvider(t, server)
+
+		resp, err := p.PerformOperation(context.Background(), diragentapi.DirAgentPerformOperationRequest{
+			Operation:          diragentapi.GetTemporaryPassword,
+			AccountImmutableID: test.immutableID,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", test.user, err)
+		}
+		password := lo.FromPtr(resp.TemporaryPassword)
+		if n := utf8.RuneCountInString(password); n < test.minLength {
+			t.Errorf("%s: password has %d characters, want at least %d", test.user, n, test.minLength)
+		}
+		for _, part := range test.forbiddenParts {
+			if strings.Contains(strings.ToLower(password), strings.ToLower(part)) {
+				t.Errorf("%s: password %q contains %q", test.user, password, part)
+			}
+		}
+
+		entry := server.Entry(test.dn)
+		if got := entry.GetAttributeValue("unicodePwd"); got != encodeUnicodePwd(password) {
+			t.Errorf("%s: unicodePwd = %x, want the quoted UTF-16LE password", test.user, got)
+		}
+		if got := entry.GetAttributeValue("pwdLastSet"); got != "0" {
+			t.Errorf("%s: pwdLastSet = %q, want 0 to require a change at next logon", test.user, got)
+		}
+	}
+}
+
+func TestEncodeUnicodePwd(t *testing.T) {
+	if got, want := encodeUnicodePwd("aé"), "\"\x00a\x00\xe9\x00\"\x00"; got != want {
+		t.Errorf("encodeUnicodePwd = %x, want %x", got, want)
+	}
+}
+
+func TestADUnlock(t *testing.T) {
+	server := newADServer(t)
+	p := newADProvider(t, server)
+
+	unlock := func(immutableID string) error {
+		_, err := p.PerformOperation(context.Background(), diragentapi.DirAgentPerformOperationRequest{
+			Operation:          diragentapi.Unlock,
+			AccountImmutableID: immutableID,
+		})
+		return err
+	}
+
+	if err := unlock(aliceGUID); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.Entry("cn=Alice Example,cn=Users,dc=example,dc=com").GetAttributeValue("lockoutTime"); got != "0" {
+		t.Errorf("lockoutTime = %q, want 0", got)
+	}
+
+	// bob, and now alice, are not locked out
+	for _, immutableID := range []string{bobGUID, aliceGUID} {
+		var codedErr directory.CodedError
+		if err := unlock(immutableID); !errors.As(err, &codedErr) || codedErr.Code != diragentapi.UnsupportedAccountState {
+			t.Errorf("unlock %s: got %v, want %s", immutableID, err, diragentapi.UnsupportedAccountState)
+		}
+	}
+}
@@ -0,0 +1,386 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirldap
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jpillora/backoff"
+)
+
+const (
+	defaultPoolSize  = 1
+	maxDialAttempts  = 5
+	pingTimeLimitSec = 10
+
+	// pagingIdleTimeout is how long the connection of a paged search is kept
+	// after its last page was read, in case the search is continued.
+	pagingIdleTimeout = 10 * time.Minute
+)
+
+// ErrPagedSearchLost is returned by SearchPage when the connection that a
+// paged search ran on was lost, or the search expired, so that its
+// remaining pages cannot be read. The search must be started again.
+var ErrPagedSearchLost = errors.New("the paged search was interrupted because its LDAP connection was lost or expired; start it again")
+
+// LDAPClient implements Client on top of a small pool of LDAP connections.
+//
+// Connections are dialed and bound lazily. When a connection turns out to
+// be closed, for example because the server restarted or dropped it after an
+// idle timeout, it is discarded and the operation is retried once on a fresh
+// connection. Dialing is retried with exponential backoff.
+type LDAPClient struct {
+	dial func() (*ldap.Conn, error)
+
+	// pool holds one slot per connection. A nil slot means that the
+	// connection has not been dialed yet or was discarded.
+	pool chan *ldap.Conn
+
+	// Each paged search runs on a connection of its own, since servers only
+	// accept a paging cookie on the connection that issued it, and many
+	// allow only one paged search per connection. Cursors start with the
+	// ID of their search's connection.
+	pagingMu    sync.Mutex
+	pagingConns map[string]*pagingConn
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewLDAPClient returns a client that keeps up to poolSize connections made
+// with dial. If keepalive is positive, idle connections are pinged at that
+// interval so that servers and firewalls do not drop them.
+func NewLDAPClient(dial func() (*ldap.Conn, error), poolSize int, keepalive time.Duration) *LDAPClient {
+	if poolSize < 1 {
+		poolSize = defaultPoolSize
+	}
+	c := &LDAPClient{
+		dial: dial,
+		pool: make(chan *ldap.Conn, poolSize),
+		stop: make(chan struct{}),
+	}
+	for range poolSize {
+		c.pool <- nil
+	}
+	if keepalive > 0 {
+		go c.keepalive(keepalive)
+	}
+	return c
+}
+
+// Bind creates a connection with the provided credentials.
+func (c *LDAPClient) Bind(username, password string) error {
+	return c.do(func(conn *ldap.Conn) error {
+		return conn.Bind(username, password)
+	})
+}
+
+// Search runs the ldap search request and returns the result
+func (c *LDAPClient) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	var result *ldap.SearchResult
+	err := c.do(func(conn *ldap.Conn) error {
+		var err error
+		result, err = conn.Search(request)
+		return err
+	})
+	return result, err
+}
+
+// Modify applies the ldap modify request
+func (c *LDAPClient) Modify(request *ldap.ModifyRequest) error {
+	return c.do(func(conn *ldap.Conn) error {
+		return conn.Modify(request)
+	})
+}
+
+// pagingConn is the connection of a paged search.
+type pagingConn struct {
+	conn     *ldap.Conn
+	lastUsed time.Time
+}
+
+// SearchPage runs one page of a paged search (RFC 2696) and returns the
+// result and the cursor of the next page, which is empty after the last
+// page. cursor is empty for the first page.
+//
+// Every page of a search runs on the same connection, which is used for
+// nothing else. If that connection is lost between pages, or the search is
+// not continued within pagingIdleTimeout, the next page fails with
+// ErrPagedSearchLost.
+func (c *LDAPClient) SearchPage(request *ldap.SearchRequest, pageSize uint32, cursor string) (*ldap.SearchResult, string, error) {
+	var id string
+	var pc *pagingConn
+	var cookie []byte
+	if cursor != "" {
+		var encodedCookie string
+		id, encodedCookie, _ = strings.Cut(cursor, ".")
+		var err error
+		cookie, err = base64.StdEncoding.DecodeString(encodedCookie)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid cursor: %w", err)
+		}
+		pc = c.takePagingConn(id)
+		if pc == nil || pc.conn.IsClosing() {
+			if pc != nil {
+				_ = pc.conn.Close()
+			}
+			return nil, "", ErrPagedSearchLost
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		if pc == nil {
+			conn, err := c.redial()
+			if err != nil {
+				return nil, "", err
+			}
+			id, pc = newPagingID(), &pagingConn{conn: conn}
+		}
+
+		pagingControl := ldap.NewControlPaging(pageSize)
+		pagingControl.SetCookie(cookie)
+		pageRequest := *request
+		pageRequest.Controls = append(slices.Clip(request.Controls), pagingControl)
+
+		result, err := pc.conn.Search(&pageRequest)
+		if err != nil {
+			_ = pc.conn.Close()
+			// Nothing is lost if the first page fails, so it is retried
+			// on a new connection.
+			if isConnectionError(err) && cursor == "" && attempt == 0 {
+				log.Printf("ldap: connection failed, reconnecting: %v", err)
+				pc = nil
+				continue
+			}
+			if isConnectionError(err) && cursor != "" {
+				log.Printf("ldap: lost the connection of a paged search: %v", err)
+				return nil, "", ErrPagedSearchLost
+			}
+			return nil, "", err
+		}
+
+		paging, ok := ldap.FindControl(result.Controls, ldap.ControlTypePaging).(*ldap.ControlPaging)
+		if !ok || len(paging.Cookie) == 0 {
+			_ = pc.conn.Close()
+			return result, "", nil
+		}
+		c.putPagingConn(id, pc)
+		return result, id + "." + base64.StdEncoding.EncodeToString(paging.Cookie), nil
+	}
+}
+
+// takePagingConn removes the connection of the paged search with id from
+// the client and returns it, or nil if there is none or the search expired.
+func (c *LDAPClient) takePagingConn(id string) *pagingConn {
+	c.pagingMu.Lock()
+	defer c.pagingMu.Unlock()
+	c.expirePagingConns()
+	pc := c.pagingConns[id]
+	delete(c.pagingConns, id)
+	return pc
+}
+
+// putPagingConn keeps the connection of the paged search with id for its
+// next page.
+func (c *LDAPClient) putPagingConn(id string, pc *pagingConn) {
+	c.pagingMu.Lock()
+	defer c.pagingMu.Unlock()
+	if c.pagingConns == nil {
+		c.pagingConns = map[string]*pagingConn{}
+	}
+	c.expirePagingConns()
+	pc.lastUsed = time.Now()
+	c.pagingConns[id] = pc
+}
+
+// expirePagingConns closes the connections of paged searches that have not
+// been continued within pagingIdleTimeout. The caller must hold pagingMu.
+func (c *LDAPClient) expirePagingConns() {
+	for id, pc := range c.pagingConns {
+		if time.Since(pc.lastUsed) > pagingIdleTimeout {
+			_ = pc.conn.Close()
+			delete(c.pagingConns, id)
+		}
+	}
+}
+
+// newPagingID returns a random identifier for a paged search.
+func newPagingID() string {
+	buf := make([]byte, 6)
+	_, _ = rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// Extended runs an extended operation, with the request's controls
+func (c *LDAPClient) Extended(request *ldap.ExtendedRequest) (*ldap.ExtendedResponse, error) {
+	var response *ldap.ExtendedResponse
+	err := c.do(func(conn *ldap.Conn) error {
+		var err error
+		response, err = conn.Extended(request)
+		return err
+	})
+	return response, err
+}
+
+// Ping checks that the server is reachable by reading the root DSE.
+func (c *LDAPClient) Ping() error {
+	return c.do(ping)
+}
+
+// Close closes all of the client's connections
+func (c *LDAPClient) Close() error {
+	c.stopOnce.Do(func() { close(c.stop) })
+
+	var errs []error
+	c.pagingMu.Lock()
+	for id, pc := range c.pagingConns {
+		errs = append(errs, pc.conn.Close())
+		delete(c.pagingConns, id)
+	}
+	c.pagingMu.Unlock()
+	for range cap(c.pool) {
+		conn := <-c.pool
+		if conn != nil {
+			errs = append(errs, conn.Close())
+		}
+		c.pool <- nil
+	}
+	return errors.Join(errs...)
+}
+
+// do runs fn on a pooled connection. If the connection fails, fn is retried
+// once on a new connection.
+func (c *LDAPClient) do(fn func(conn *ldap.Conn) error) error {
+	conn := <-c.pool
+	defer func() { c.pool <- conn }()
+
+	for attempt := 0; ; attempt++ {
+		if conn == nil || conn.IsClosing() {
+			if conn != nil {
+				_ = conn.Close()
+			}
+			var err error
+			conn, err = c.redial()
+			if err != nil {
+				return err
+			}
+		}
+
+		err := fn(conn)
+		if err == nil || !isConnectionError(err) || attempt > 0 {
+			return err
+		}
+		log.Printf("ldap: connection failed, reconnecting: %v", err)
+		_ = conn.Close()
+		conn = nil
+	}
+}
+
+// redial dials and binds a new connection, with backoff.
+func (c *LDAPClient) redial() (*ldap.Conn, error) {
+	bo := backoff.Backoff{Min: 250 * time.Millisecond, Max: 10 * time.Second}
+	for {
+		conn, err := c.dial()
+		if err == nil {
+			return conn, nil
+		}
+		if !isConnectionError(err) || bo.Attempt() >= maxDialAttempts-1 {
+			return nil, fmt.Errorf("cannot connect to LDAP server: %w", err)
+		}
+		delay := bo.Duration()
+		log.Printf("ldap: cannot connect, retrying in %s: %v", delay, err)
+		select {
+		case <-c.stop:
+			return nil, fmt.Errorf("cannot connect to LDAP server: %w", err)
+		case <-time.After(delay):
+		}
+	}
+}
+
+// keepalive pings each idle connection every interval. Connections that
+// fail the ping are discarded and redialed on next use.
+func (c *LDAPClient) keepalive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+
+		c.pagingMu.Lock()
+		c.expirePagingConns()
+		c.pagingMu.Unlock()
+
+		for range cap(c.pool) {
+			var conn *ldap.Conn
+			select {
+			case conn = <-c.pool:
+			default:
+				// the remaining connections are in use, so they are not idle
+				continue
+			}
+			if conn != nil && !conn.IsClosing() {
+				if err := ping(conn); err != nil {
+					log.Printf("ldap: keepalive failed, dropping connection: %v", err)
+					_ = conn.Close()
+					conn = nil
+				}
+			}
+			c.pool <- conn
+		}
+	}
+}
+
+// ping reads the root DSE, which every LDAPv3 server exposes.
+func ping(conn *ldap.Conn) error {
+	_, err := conn.Search(ldap.NewSearchRequest(
+		"",
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		1,
+		pingTimeLimitSec,
+		false,
+		"(objectClass=*)",
+		[]string{"supportedLDAPVersion"},
+		nil,
+	))
+	return err
+}
+
+// isConnectionError returns true if err means that the connection to the
+// server is unusable, as opposed to the server rejecting the request.
+func isConnectionError(err error) bool {
+	// go-ldap does not wrap the error when a request cannot be written to
+	// the connection, e.g. after the server reset it.
+	if err != nil && strings.HasPrefix(err.Error(), "unable to send request") {
+		return true
+	}
+	return ldap.IsErrorAnyOf(err,
+		ldap.ErrorNetwork,
+		ldap.LDAPResultUnavailable,
+		ldap.LDAPResultServerDown,
+		ldap.LDAPResultConnectError)
+}
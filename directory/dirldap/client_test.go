@@ -0,0 +1,210 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirldap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/samber/lo"
+
+	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/internal/config"
+)
+
+// newTestProvider returns a provider that talks to server with a pool of
+// poolSize connections.
+func newTestProvider(t *testing.T, server *testServer, poolSize int, schema config.LDAPSchema) *Provider {
+	p := &Provider{
+		_client: NewLDAPClient(server.dial, poolSize, 0),
+		Config: &config.LDAPConfig{
+			BaseDN:   "dc=example,dc=com",
+			PageSize: 2,
+			PoolSize: poolSize,
+			Schema:   schema,
+		},
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+func testUsers(n int) []*ldap.Entry {
+	entries := []*ldap.Entry{testEntry("ou=people,dc=example,dc=com", map[string][]string{
+		"objectClass": {"organizationalUnit"},
+	})}
+	for i := range n {
+		entries = append(entries, testEntry(fmt.Sprintf("uid=user%d,ou=people,dc=example,dc=com", i), map[string][]string{
+			"objectClass": {"inetOrgPerson"},
+			"uid":         {fmt.Sprintf("user%d", i)},
+			"cn":          {fmt.Sprintf("User %d", i)},
+			"entryUUID":   {fmt.Sprintf("uuid-%d", i)},
+		}))
+	}
+	return entries
+}
+
+// listAllAccounts pages through list_accounts and returns the immutable IDs
+// in the order they were returned. beforePage, if set, is called before
+// each page is requested.
+func listAllAccounts(t *testing.T, p *Provider, req diragentapi.DirAgentListAccountsRequest, beforePage func(page int)) []string {
+	t.Helper()
+	var ids []string
+	for page := 0; ; page++ {
+		if page > 100 {
+			t.Fatal("too many pages")
+		}
+		if beforePage != nil {
+			beforePage(page)
+		}
+		resp, err := p.ListAccounts(context.Background(), req)
+		if err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		for _, account := range resp.Accounts {
+			ids = append(ids, account.ImmutableID)
+		}
+		if resp.NextCursor == nil {
+			return ids
+		}
+		req.Cursor = resp.NextCursor
+	}
+}
+
+func TestListAccountsPagesOnOneConnection(t *testing.T) {
+	server := newTestServer(t, testUsers(5)...)
+	p := newTestProvider(t, server, 2, config.LDAPSchema{})
+
+	// Requests between pages use the pool, and must not disturb the paged
+	// search.
+	ids := listAllAccounts(t, p, diragentapi.DirAgentListAccountsRequest{}, func(int) {
+		if _, err := p.GetAccount(context.Background(), diragentapi.DirAgentGetAccountRequest{
+			Ref: diragentapi.DirAgentAccountRef{ID: lo.ToPtr("user0")},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if want := []string{"uuid-0", "uuid-1", "uuid-2", "uuid-3", "uuid-4"}; !slices.Equal(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+}
+
+func TestListAccountsFailsAfterReconnect(t *testing.T) {
+	server := newTestServer(t, testUsers(5)...)
+	p := newTestProvider(t, server, 1, config.LDAPSchema{})
+
+	resp, err := p.ListAccounts(context.Background(), diragentapi.DirAgentListAccountsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.dropConnections()
+
+	// The paging cookie belongs to the lost connection, so the listing
+	// fails rather than starting again and returning duplicates.
+	_, err = p.ListAccounts(context.Background(), diragentapi.DirAgentListAccountsRequest{Cursor: resp.NextCursor})
+	if !errors.Is(err, ErrPagedSearchLost) {
+		t.Errorf("got %v, want %v", err, ErrPagedSearchLost)
+	}
+
+	// a new listing works
+	ids := listAllAccounts(t, p, diragentapi.DirAgentListAccountsRequest{}, nil)
+	if want := []string{"uuid-0", "uuid-1", "uuid-2", "uuid-3", "uuid-4"}; !slices.Equal(ids, want) {
+		t.Errorf("got %v, want %v", ids, want)
+	}
+}
+
+func TestSearchPageInterleaved(t *testing.T) {
+	server := newTestServer(t, testUsers(5)...)
+	client := NewLDAPClient(server.dial, 1, 0)
+	defer func() { _ = client.Close() }()
+
+	uids := func(result *ldap.SearchResult) []string {
+		return lo.Map(result.Entries, func(entry *ldap.Entry, _ int) string { return entry.GetAttributeValue("uid") })
+	}
+	request := ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		0, 0, false, "(objectClass=inetOrgPerson)", []string{"uid"}, nil)
+
+	// two searches, such as list_accounts and list_groups, are paged
+	// through at the same time, each on its own connection
+	var got [2][]string
+	var cursors [2]string
+	for page := 0; page < 3; page++ {
+		for i := range cursors {
+			if page > 0 && cursors[i] == "" {
+				continue
+			}
+			result, cursor, err := client.SearchPage(request, uint32(2+i), cursors[i])
+			if err != nil {
+				t.Fatalf("search %d, page %d: %v", i, page, err)
+			}
+			got[i] = append(got[i], uids(result)...)
+			cursors[i] = cursor
+		}
+	}
+	want := []string{"user0", "user1", "user2", "user3", "user4"}
+	for i := range got {
+		if !slices.Equal(got[i], want) || cursors[i] != "" {
+			t.Errorf("search %d: got %v %q, want %v and no cursor", i, got[i], cursors[i], want)
+		}
+	}
+	if n := len(client.pagingConns); n != 0 {
+		t.Errorf("%d connections left after the searches ended", n)
+	}
+}
+
+func TestSearchPageRejectsCursorOfOtherClient(t *testing.T) {
+	server := newTestServer(t, testUsers(3)...)
+	client := NewLDAPClient(server.dial, 1, 0)
+	defer func() { _ = client.Close() }()
+	other := NewLDAPClient(server.dial, 1, 0)
+	defer func() { _ = other.Close() }()
+
+	request := ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		0, 0, false, "(objectClass=inetOrgPerson)", []string{"uid"}, nil)
+
+	_, cursor, err := client.SearchPage(request, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := other.SearchPage(request, 2, cursor); !errors.Is(err, ErrPagedSearchLost) {
+		t.Errorf("other client: got %v, want %v", err, ErrPagedSearchLost)
+	}
+	if _, _, err := client.SearchPage(request, 2, cursor); err != nil {
+		t.Errorf("second page: %v", err)
+	}
+}
+
+func TestSearchPageExpires(t *testing.T) {
+	server := newTestServer(t, testUsers(3)...)
+	client := NewLDAPClient(server.dial, 1, 0)
+	defer func() { _ = client.Close() }()
+
+	request := ldap.NewSearchRequest("dc=example,dc=com", ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		0, 0, false, "(objectClass=inetOrgPerson)", []string{"uid"}, nil)
+	_, cursor, err := client.SearchPage(request, 2, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pc := range client.pagingConns {
+		pc.lastUsed = pc.lastUsed.Add(-pagingIdleTimeout - time.Second)
+	}
+	if _, _, err := client.SearchPage(request, 2, cursor); !errors.Is(err, ErrPagedSearchLost) {
+		t.Errorf("got %v, want %v", err, ErrPagedSearchLost)
+	}
+}
@@ -19,11 +19,13 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/go-ldap/ldap/v3"
 	"github.com/samber/lo"
 
 	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/directory"
 	"github.com/nametaginc/cli/internal/config"
 )
 
@@ -32,70 +34,56 @@ const timeFormat = "20060102150405Z"
 // Provider represents the directory provider
 type Provider struct {
 	_client Client
+	_schema *config.LDAPSchema
 	Config  *config.LDAPConfig
+
+	// RefreshCredentials is called when the server rejects the bind
+	// password, and may update Config.BindPassword.
+	RefreshCredentials directory.RefreshCredentialsFunc
 }
 
 // Client defines an interface for client operations.
 type Client interface {
 	Bind(username, password string) error
 	Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+	SearchPage(request *ldap.SearchRequest, pageSize uint32, cursor string) (*ldap.SearchResult, string, error)
 	Modify(request *ldap.ModifyRequest) error
+	Extended(request *ldap.ExtendedRequest) (*ldap.ExtendedResponse, error)
+	Ping() error
 	Close() error
 }
 
-// LDAPClient defines an interface for LDAP operations.
-type LDAPClient struct {
-	conn *ldap.Conn
-}
-
-// Bind creates a connection with the provided credentials.
-func (r *LDAPClient) Bind(username, password string) error {
-	return r.conn.Bind(username, password)
-}
-
-// Search runs the ldap search request and returns the result
-func (r *LDAPClient) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
-	return r.conn.Search(request)
-}
-
-// Modify applies the ldap modify request
-func (r *LDAPClient) Modify(request *ldap.ModifyRequest) error {
-	return r.conn.Modify(request)
-}
-
-// Close cleans up ant client related connections
-func (r *LDAPClient) Close() error {
-	return r.conn.Close()
-}
-
 func (p *Provider) client() (Client, error) {
 	if p._client == nil {
-		// Connect to LDAP server
-		client, err := ldap.DialURL(p.Config.LDAPUrl)
-		if err != nil {
-			return nil, err
-		}
-
-		// Bind with credentials
-		err = client.Bind(p.Config.BindDN, p.Config.BindPassword)
-		if err != nil {
-			return nil, err
-		}
-
-		p._client = &LDAPClient{
-			conn: client,
-		}
+		// Connections are made on first use, and remade if they are closed.
+		p._client = NewLDAPClient(p.dial, p.Config.PoolSize, time.Duration(p.Config.KeepaliveInterval))
 	}
 	return p._client, nil
 }
 
 // Close cleans up resources associated with the Provider
 func (p *Provider) Close() error {
+	if p._client == nil {
+		return nil
+	}
 	return p._client.Close()
 }
 
 // Configure returns static information about the integration
 func (p *Provider) Configure(ctx context.Context, req diragentapi.DirAgentConfigureRequest) (*diragentapi.DirAgentConfigureResponse, error) {
+	if _, err := p.schema(); err != nil {
+		return nil, directory.CodedError{
+			Code:    diragentapi.ConfigurationError,
+			Message: err.Error(),
+		}
+	}
+	if err := p.validateTransport(); err != nil {
+		return nil, directory.CodedError{
+			Code:    diragentapi.ConfigurationError,
+			Message: err.Error(),
+		}
+	}
+
 	client, err := p.client()
 	if err != nil {
 		return nil, err
@@ -115,7 +103,7 @@ func (p *Provider) Configure(ctx context.Context, req diragentapi.DirAgentConfig
 			0,
 			false,
 			"(objectClass=*)",
-			[]string{"namingContexts"},
+			[]string{"namingContexts", "defaultNamingContext"},
 			nil,
 		)
 
@@ -126,7 +114,10 @@ func (p *Provider) Configure(ctx context.Context, req diragentapi.DirAgentConfig
 
 		if len(result.Entries) > 0 {
 			entry := result.Entries[0]
-			name = entry.GetAttributeValue("namingContexts")
+			// Active Directory lists several naming contexts, the domain is the default one
+			name, _ = lo.Coalesce(
+				entry.GetAttributeValue("defaultNamingContext"),
+				entry.GetAttributeValue("namingContexts"))
 		} else {
 			return nil, fmt.Errorf("unable to find Root DSE")
 		}
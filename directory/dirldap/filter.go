@@ -0,0 +1,80 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirldap
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Filters are always built with these helpers so that values received from
+// the server are escaped and cannot change the meaning of a search.
+
+// attributeNameRegexp matches an attribute description: a name or OID,
+// optionally followed by options such as ";binary".
+var attributeNameRegexp = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9-]*|[0-9]+(\.[0-9]+)+)(;[A-Za-z0-9-]+)*$`)
+
+// equalityFilter returns a filter matching entries where attribute is value.
+func equalityFilter(attribute, value string) string {
+	return fmt.Sprintf("(%s=%s)", attribute, ldap.EscapeFilter(value))
+}
+
+// prefixFilter returns a filter matching entries where attribute starts with
+// prefix. An empty prefix matches every entry that has the attribute.
+func prefixFilter(attribute, prefix string) string {
+	return fmt.Sprintf("(%s=%s*)", attribute, ldap.EscapeFilter(prefix))
+}
+
+// greaterOrEqualFilter returns a filter matching entries where attribute is
+// at least value.
+func greaterOrEqualFilter(attribute, value string) string {
+	return fmt.Sprintf("(%s>=%s)", attribute, ldap.EscapeFilter(value))
+}
+
+// andFilter returns a filter matching entries that match all of filters.
+func andFilter(filters ...string) string {
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return "(&" + strings.Join(filters, "") + ")"
+}
+
+// orFilter returns a filter matching entries that match any of filters.
+func orFilter(filters ...string) string {
+	if len(filters) == 1 {
+		return filters[0]
+	}
+	return "(|" + strings.Join(filters, "") + ")"
+}
+
+// validateFilter checks that a configured filter is well formed.
+func validateFilter(name, filter string) error {
+	if _, err := ldap.CompileFilter(filter); err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, filter, err)
+	}
+	return nil
+}
+
+// validateAttributeName checks that a configured attribute name is well
+// formed, since it is used to build filters.
+func validateAttributeName(name, attribute string) error {
+	if !attributeNameRegexp.MatchString(attribute) {
+		return fmt.Errorf("invalid %s %q", name, attribute)
+	}
+	return nil
+}
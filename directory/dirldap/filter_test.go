@@ -0,0 +1,154 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirldap
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+
+	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/internal/config"
+)
+
+func TestFilters(t *testing.T) {
+	for _, test := range []struct {
+		got, want string
+	}{
+		{equalityFilter("uid", "alice"), `(uid=alice)`},
+		{equalityFilter("uid", "*"), `(uid=\2a)`},
+		{equalityFilter("cn", "a)(uid=*"), `(cn=a\29\28uid=\2a)`},
+		{equalityFilter("cn", `back\slash`), `(cn=back\5cslash)`},
+		{equalityFilter("cn", "nul\x00"), `(cn=nul\00)`},
+		{prefixFilter("cn", "Eng"), `(cn=Eng*)`},
+		{prefixFilter("cn", "*"), `(cn=\2a*)`},
+		{prefixFilter("cn", ""), `(cn=*)`},
+		{greaterOrEqualFilter("modifyTimestamp", "20260101000000Z"), `(modifyTimestamp>=20260101000000Z)`},
+		{greaterOrEqualFilter("modifyTimestamp", "*)(x=1"), `(modifyTimestamp>=\2a\29\28x=1)`},
+		{andFilter("(a=1)"), `(a=1)`},
+		{andFilter("(a=1)", "(b=2)"), `(&(a=1)(b=2))`},
+		{orFilter("(a=1)"), `(a=1)`},
+		{orFilter("(a=1)", "(b=2)"), `(|(a=1)(b=2))`},
+	} {
+		if test.got != test.want {
+			t.Errorf("got %s, want %s", test.got, test.want)
+		}
+		if _, err := ldap.CompileFilter(test.got); err != nil {
+			t.Errorf("%s: %v", test.got, err)
+		}
+	}
+
+	if got, want := escapeBytes([]byte{0x00, 0x2a, 0xff}), `\00\2a\ff`; got != want {
+		t.Errorf("escapeBytes = %s, want %s", got, want)
+	}
+}
+
+func TestRDNFilter(t *testing.T) {
+	for _, test := range []struct {
+		dn   string
+		want string
+		ok   bool
+	}{
+		{"cn=Sales,ou=groups,dc=example,dc=com", `(cn=Sales)`, true},
+		{`cn=Eng\, Sales,ou=groups,dc=example,dc=com`, `(cn=Eng, Sales)`, true},
+		{`cn=a*b+uid=x,dc=example,dc=com`, `(&(cn=a\2ab)(uid=x))`, true},
+		{"not a dn", "", false},
+	} {
+		got, ok := rdnFilter(test.dn)
+		if got != test.want || ok != test.ok {
+			t.Errorf("rdnFilter(%q) = %s, %t, want %s, %t", test.dn, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestValidateAttributeName(t *testing.T) {
+	for _, test := range []struct {
+		attribute string
+		valid     bool
+	}{
+		{"uid", true},
+		{"msDS-ResultantPSO", true},
+		{"userCertificate;binary", true},
+		{"2.5.4.3", true},
+		{"", false},
+		{"1uid", false},
+		{"uid=*", false},
+		{"cn)(uid", false},
+		{"2.5.", false},
+	} {
+		if err := validateAttributeName("attribute", test.attribute); (err == nil) != test.valid {
+			t.Errorf("validateAttributeName(%q) = %v, want valid %t", test.attribute, err, test.valid)
+		}
+	}
+}
+
+func TestListGroupsByNamePrefix(t *testing.T) {
+	server := newTestServer(t,
+		testEntry("cn=Engineering,ou=groups,dc=example,dc=com", map[string][]string{
+			"objectClass": {"groupOfNames"},
+			"cn":          {"Engineering"},
+			"entryUUID":   {"uuid-eng"},
+		}),
+		testEntry("cn=Engagement,ou=groups,dc=example,dc=com", map[string][]string{
+			"objectClass": {"groupOfNames"},
+			"cn":          {"Engagement"},
+			"entryUUID":   {"uuid-engagement"},
+		}),
+		testEntry("cn=Sales,ou=groups,dc=example,dc=com", map[string][]string{
+			"objectClass": {"groupOfNames"},
+			"cn":          {"Sales"},
+			"entryUUID":   {"uuid-sales"},
+		}),
+		testEntry("cn=*,ou=groups,dc=example,dc=com", map[string][]string{
+			"objectClass": {"groupOfNames"},
+			"cn":          {"*"},
+			"entryUUID":   {"uuid-star"},
+		}),
+	)
+	p := newTestProvider(t, server, 1, config.LDAPSchema{})
+
+	for _, test := range []struct {
+		prefix string
+		want   []string
+	}{
+		{"Eng", []string{"Engineering", "Engagement"}},
+		{"engi", []string{"Engineering"}},
+		{"*", []string{"*"}},
+		{"", []string{"Engineering", "Engagement", "Sales", "*"}},
+	} {
+		var names []string
+		var cursor *string
+		for {
+			resp, err := p.ListGroups(context.Background(), diragentapi.DirAgentListGroupsRequest{
+				NamePrefix: &test.prefix,
+				Cursor:     cursor,
+			})
+			if err != nil {
+				t.Fatalf("%q: %v", test.prefix, err)
+			}
+			for _, group := range resp.Groups {
+				names = append(names, group.Name)
+			}
+			if cursor = resp.NextCursor; cursor == nil {
+				break
+			}
+		}
+		if !slices.Equal(names, test.want) {
+			t.Errorf("prefix %q: got %v, want %v", test.prefix, names, test.want)
+		}
+	}
+}
@@ -18,9 +18,10 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"time"
+	"strings"
 
 	"github.com/go-ldap/ldap/v3"
+	"github.com/samber/lo"
 
 	"github.com/nametaginc/cli/diragentapi"
 )
@@ -36,13 +37,24 @@ func (p *Provider) GetAccount(ctx context.Context, req diragentapi.DirAgentGetAc
 		return nil, fmt.Errorf("could not get client from provider: %w", err)
 	}
 
-	attributes := []string{
-		"entryUUID",       // Immutable ID
-		"uid",             // Username
-		"mail",            // Email address
-		"cn",              // Common Name
-		"modifyTimestamp", // When last modified
-		"memberOf",        // Indicates group membership
+	schema, err := p.schema()
+	if err != nil {
+		return nil, err
+	}
+
+	// Match either of the fields of the ref, if both are set.
+	var refFilters []string
+	if req.Ref.ImmutableID != nil {
+		// a malformed immutable ID cannot match any account
+		if idFilter, err := immutableIDFilter(schema, *req.Ref.ImmutableID); err == nil {
+			refFilters = append(refFilters, idFilter)
+		}
+	}
+	if req.Ref.ID != nil {
+		refFilters = append(refFilters, identifierFilter(schema, *req.Ref.ID))
+	}
+	if len(refFilters) == 0 {
+		return &diragentapi.DirAgentGetAccountResponse{}, nil
 	}
 
 	// Search filter for users
@@ -53,65 +65,25 @@ func (p *Provider) GetAccount(ctx context.Context, req diragentapi.DirAgentGetAc
 		0,
 		0,
 		false,
-		fmt.Sprintf("(entryUUID=%s)", *req.Ref.ImmutableID), // Filter for user objects
-		attributes,
+		andFilter(schema.UserFilter, orFilter(refFilters...)),
+		userAttributes(schema, schema.MemberOfAttribute),
 		nil,
 	)
 
 	result, err := client.Search(searchRequest)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching user %s: %w", *req.Ref.ImmutableID, err)
+		return nil, fmt.Errorf("error fetching user %s: %w", refString(req.Ref), err)
 	}
 
 	var accounts []diragentapi.DirAgentAccount
 	for _, entry := range result.Entries {
-		externalIDs := []string{}
-		if entry.GetAttributeValue("mail") != "" {
-			externalIDs = append(externalIDs, entry.GetAttributeValue("mail"))
-		}
-		if entry.GetAttributeValue("uid") != "" {
-			externalIDs = append(externalIDs, entry.GetAttributeValue("uid"))
+		userGroups, err := p.userGroups(client, schema, entry)
+		if err != nil {
+			return nil, err
 		}
-		modifyTime, _ := time.Parse(timeFormat, entry.GetAttributeValue("modifyTimestamp"))
 
-		groups := entry.GetAttributeValues("memberOf")
-		userGroups := []diragentapi.DirAgentGroup{}
-		for _, groupDN := range groups {
-			// Search for the group to get its entryUUID
-			groupSearchRequest := ldap.NewSearchRequest(
-				groupDN, // Search directly using the group's DN
-				ldap.ScopeBaseObject,
-				ldap.NeverDerefAliases,
-				0,
-				0,
-				false,
-				"(objectClass=*)",
-				[]string{"entryUUID"}, // We only need the entryUUID
-				nil,
-			)
-
-			groupResult, err := client.Search(groupSearchRequest)
-			if err != nil {
-				return nil, fmt.Errorf("error fetching group %s: %w", groupDN, err)
-			}
-
-			if len(groupResult.Entries) > 0 {
-				groupEntry := groupResult.Entries[0]
-				userGroups = append(userGroups, diragentapi.DirAgentGroup{
-					ImmutableID: groupEntry.GetAttributeValue("entryUUID"),
-					Name:        groupDN,
-					Kind:        "group",
-				})
-			}
-		}
-
-		account := diragentapi.DirAgentAccount{
-			ImmutableID: entry.GetAttributeValue("entryUUID"),
-			IDs:         externalIDs,
-			Name:        entry.GetAttributeValue("cn"),
-			UpdatedAt:   &modifyTime,
-			Groups:      &userGroups,
-		}
+		account := p.account(schema, entry)
+		account.Groups = &userGroups
 
 		log.Printf("user account: %+v", account)
 		accounts = append(accounts, account)
@@ -119,3 +91,8 @@ func (p *Provider) GetAccount(ctx context.Context, req diragentapi.DirAgentGetAc
 
 	return &diragentapi.DirAgentGetAccountResponse{Accounts: accounts}, nil
 }
+
+// refString describes an account ref for error messages.
+func refString(ref diragentapi.DirAgentAccountRef) string {
+	return strings.Join(lo.Compact([]string{lo.FromPtr(ref.ImmutableID), lo.FromPtr(ref.ID)}), " ")
+}
@@ -0,0 +1,364 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirldap
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/samber/lo"
+
+	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/internal/config"
+)
+
+// groupBatchSize is the number of groups fetched by a single search.
+const groupBatchSize = 50
+
+// groupResolver finds the groups a user belongs to.
+type groupResolver struct {
+	p      *Provider
+	client Client
+	schema *config.LDAPSchema
+
+	// groups are the groups found so far, by normalized DN, in the order
+	// they were found. Groups are never visited twice, which stops cycles
+	// of nested groups.
+	groups map[string]*ldap.Entry
+	order  []string
+}
+
+// userGroups returns the groups that user belongs to, according to the
+// group membership settings of the schema. The user entry must have been
+// fetched with the member of attribute.
+func (p *Provider) userGroups(client Client, schema *config.LDAPSchema, user *ldap.Entry) ([]diragentapi.DirAgentGroup, error) {
+	r := groupResolver{
+		p:      p,
+		client: client,
+		schema: schema,
+		groups: map[string]*ldap.Entry{},
+	}
+
+	var direct []*ldap.Entry
+	var err error
+	if schema.GroupMembership == groupMembershipMemberOf {
+		direct, err = r.fetchGroups(user.GetAttributeValues(schema.MemberOfAttribute))
+	} else {
+		direct, err = r.searchGroupsWithMembers([]string{user.DN})
+	}
+	if err != nil {
+		return nil, err
+	}
+	frontier := r.add(direct)
+
+	if schema.DynamicGroupFilter != "" {
+		dynamic, err := r.dynamicGroups(user)
+		if err != nil {
+			return nil, err
+		}
+		frontier = append(frontier, r.add(dynamic)...)
+	}
+
+	for schema.NestedGroups && len(frontier) > 0 {
+		var parents []*ldap.Entry
+		if schema.GroupMembership == groupMembershipMemberOf {
+			var parentDNs []string
+			for _, group := range frontier {
+				parentDNs = append(parentDNs, group.GetAttributeValues(schema.MemberOfAttribute)...)
+			}
+			parents, err = r.fetchGroups(parentDNs)
+		} else {
+			parents, err = r.searchGroupsWithMembers(lo.Map(frontier, func(group *ldap.Entry, _ int) string {
+				return group.DN
+			}))
+		}
+		if err != nil {
+			return nil, err
+		}
+		frontier = r.add(parents)
+	}
+
+	userGroups := []diragentapi.DirAgentGroup{}
+	for _, key := range r.order {
+		userGroups = append(userGroups, group(schema, r.groups[key]))
+	}
+	return userGroups, nil
+}
+
+// add records groups, returning the ones that had not been seen before.
+func (r *groupResolver) add(groups []*ldap.Entry) []*ldap.Entry {
+	var added []*ldap.Entry
+	for _, group := range groups {
+		key := normalizeDN(group.DN)
+		if _, seen := r.groups[key]; seen {
+			continue
+		}
+		r.groups[key] = group
+		r.order = append(r.order, key)
+		added = append(added, group)
+	}
+	return added
+}
+
+// groupAttributes returns the attributes to fetch for group entries.
+func (r *groupResolver) groupAttributes() []string {
+	return []string{r.schema.ImmutableIDAttribute, r.schema.GroupNameAttribute, r.schema.MemberOfAttribute}
+}
+
+// fetchGroups returns the group entries with the given DNs, skipping groups
+// that have already been seen. Groups are fetched in batches by searching for
+// their relative DNs, which every server can match, and then matching the
+// full DNs of the results.
+func (r *groupResolver) fetchGroups(dns []string) ([]*ldap.Entry, error) {
+	wanted := map[string]string{}
+	for _, dn := range dns {
+		key := normalizeDN(dn)
+		if _, seen := r.groups[key]; !seen {
+			wanted[key] = dn
+		}
+	}
+
+	var groups []*ldap.Entry
+	for _, batch := range lo.Chunk(lo.Keys(wanted), groupBatchSize) {
+		var rdnFilters []string
+		for _, key := range batch {
+			if filter, ok := rdnFilter(wanted[key]); ok {
+				rdnFilters = append(rdnFilters, filter)
+			}
+		}
+		if len(rdnFilters) == 0 {
+			continue
+		}
+
+		result, err := r.client.Search(ldap.NewSearchRequest(
+			r.p.Config.BaseDN,
+			ldap.ScopeWholeSubtree,
+			ldap.NeverDerefAliases,
+			0,
+			0,
+			false,
+			andFilter(r.schema.GroupFilter, orFilter(rdnFilters...)),
+			r.groupAttributes(),
+			nil,
+		))
+		if err != nil {
+			return nil, fmt.Errorf("error fetching groups: %w", err)
+		}
+		for _, entry := range result.Entries {
+			key := normalizeDN(entry.DN)
+			if _, ok := wanted[key]; ok {
+				groups = append(groups, entry)
+				delete(wanted, key)
+			}
+		}
+	}
+
+	// Groups outside of the base DN are fetched one at a time.
+	for _, dn := range wanted {
+		result, err := r.client.Search(ldap.NewSearchRequest(
+			dn, // Search directly using the group's DN
+			ldap.ScopeBaseObject,
+			ldap.NeverDerefAliases,
+			0,
+			0,
+			false,
+			"(objectClass=*)",
+			r.groupAttributes(),
+			nil,
+		))
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error fetching group %s: %w", dn, err)
+		}
+		groups = append(groups, result.Entries...)
+	}
+
+	return groups, nil
+}
+
+// searchGroupsWithMembers returns the groups whose member attribute
+// contains any of memberDNs.
+func (r *groupResolver) searchGroupsWithMembers(memberDNs []string) ([]*ldap.Entry, error) {
+	var groups []*ldap.Entry
+	for _, batch := range lo.Chunk(memberDNs, groupBatchSize) {
+		memberFilters := lo.Map(batch, func(dn string, _ int) string {
+			return equalityFilter(r.schema.MemberAttribute, dn)
+		})
+		result, err := r.client.Search(ldap.NewSearchRequest(
+			r.p.Config.BaseDN,
+			ldap.ScopeWholeSubtree,
+			ldap.NeverDerefAliases,
+			0,
+			0,
+			false,
+			andFilter(r.schema.GroupFilter, orFilter(memberFilters...)),
+			r.groupAttributes(),
+			nil,
+		))
+		if err != nil {
+			return nil, fmt.Errorf("error fetching groups: %w", err)
+		}
+		groups = append(groups, result.Entries...)
+	}
+	return groups, nil
+}
+
+// dynamicGroups returns the dynamic groups that user belongs to, which are
+// those with a member URL that matches the user.
+func (r *groupResolver) dynamicGroups(user *ldap.Entry) ([]*ldap.Entry, error) {
+	result, err := r.client.Search(ldap.NewSearchRequest(
+		r.p.Config.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		r.schema.DynamicGroupFilter,
+		append(r.groupAttributes(), r.schema.MemberURLAttribute),
+		nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching dynamic groups: %w", err)
+	}
+
+	var groups []*ldap.Entry
+	for _, group := range result.Entries {
+		for _, memberURL := range group.GetAttributeValues(r.schema.MemberURLAttribute) {
+			ok, err := r.matchesMemberURL(user, memberURL)
+			if err != nil {
+				return nil, fmt.Errorf("group %s: %w", group.DN, err)
+			}
+			if ok {
+				groups = append(groups, group)
+				break
+			}
+		}
+	}
+	return groups, nil
+}
+
+// matchesMemberURL returns true if user is selected by the LDAP URL
+// (RFC 4516) of a dynamic group.
+func (r *groupResolver) matchesMemberURL(user *ldap.Entry, memberURL string) (bool, error) {
+	base, scope, filter, err := parseMemberURL(memberURL)
+	if err != nil {
+		return false, err
+	}
+
+	userDN, err := ldap.ParseDN(user.DN)
+	if err != nil {
+		return false, err
+	}
+	baseDN, err := ldap.ParseDN(base)
+	if err != nil {
+		return false, fmt.Errorf("invalid member URL %q: %w", memberURL, err)
+	}
+
+	switch scope {
+	case ldap.ScopeBaseObject:
+		if !userDN.EqualFold(baseDN) {
+			return false, nil
+		}
+	case ldap.ScopeSingleLevel:
+		if len(userDN.RDNs) != len(baseDN.RDNs)+1 || !baseDN.AncestorOfFold(userDN) {
+			return false, nil
+		}
+	default:
+		if !userDN.EqualFold(baseDN) && !baseDN.AncestorOfFold(userDN) {
+			return false, nil
+		}
+	}
+
+	// The user is in scope, so the server can evaluate the filter.
+	result, err := r.client.Search(ldap.NewSearchRequest(
+		user.DN,
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		filter,
+		[]string{"1.1"}, // No attributes
+		nil,
+	))
+	if err != nil {
+		return false, fmt.Errorf("error evaluating member URL %q: %w", memberURL, err)
+	}
+	return len(result.Entries) > 0, nil
+}
+
+// parseMemberURL parses an LDAP URL of the form
+// ldap:///base?attributes?scope?filter. The host and attributes are ignored.
+func parseMemberURL(memberURL string) (base string, scope int, filter string, err error) {
+	u, err := url.Parse(memberURL)
+	if err != nil {
+		return "", 0, "", fmt.Errorf("invalid member URL %q: %w", memberURL, err)
+	}
+	base = strings.TrimPrefix(u.Path, "/")
+
+	parts := strings.Split(u.RawQuery, "?")
+	scope = ldap.ScopeBaseObject
+	if len(parts) > 1 {
+		switch strings.ToLower(parts[1]) {
+		case "", "base":
+		case "one":
+			scope = ldap.ScopeSingleLevel
+		case "sub":
+			scope = ldap.ScopeWholeSubtree
+		default:
+			return "", 0, "", fmt.Errorf("invalid member URL %q: unknown scope %q", memberURL, parts[1])
+		}
+	}
+
+	filter = "(objectClass=*)"
+	if len(parts) > 2 && parts[2] != "" {
+		filter, err = url.PathUnescape(parts[2])
+		if err != nil {
+			return "", 0, "", fmt.Errorf("invalid member URL %q: %w", memberURL, err)
+		}
+		if err := validateFilter("member URL filter", filter); err != nil {
+			return "", 0, "", err
+		}
+	}
+	return base, scope, filter, nil
+}
+
+// rdnFilter returns a filter matching entries with the same relative DN as
+// dn, or false if dn cannot be parsed.
+func rdnFilter(dn string) (string, bool) {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 {
+		return "", false
+	}
+	var filters []string
+	for _, attribute := range parsed.RDNs[0].Attributes {
+		filters = append(filters, equalityFilter(attribute.Type, attribute.Value))
+	}
+	return andFilter(filters...), true
+}
+
+// normalizeDN returns a form of dn suitable for comparing DNs, ignoring case
+// and spacing.
+func normalizeDN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return strings.ToLower(dn)
+	}
+	return strings.ToLower(parsed.String())
+}
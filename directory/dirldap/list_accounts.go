@@ -16,9 +16,7 @@ package dirldap
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
-	"time"
 
 	"github.com/go-ldap/ldap/v3"
 	"github.com/samber/lo"
@@ -34,33 +32,17 @@ func (p *Provider) ListAccounts(ctx context.Context, req diragentapi.DirAgentLis
 		return nil, fmt.Errorf("could not get client from provider: %w", err)
 	}
 
-	attributes := []string{
-		"entryUUID",       // Immutable ID
-		"uid",             // Username
-		"mail",            // Email address
-		"cn",              // Common Name
-		"modifyTimestamp", // When last modified
+	schema, err := p.schema()
+	if err != nil {
+		return nil, err
 	}
 
 	var filterString string
 	if req.UpdatedAfter != nil {
-		timeFilter := req.UpdatedAfter.Format(timeFormat)
-		filterString = fmt.Sprintf("(&(objectClass=inetOrgPerson)(modifyTimestamp>=%s))", timeFilter)
+		timeFilter := req.UpdatedAfter.UTC().Format(schema.TimestampFormat)
+		filterString = andFilter(schema.UserFilter, greaterOrEqualFilter(schema.ModifyTimestampAttribute, timeFilter))
 	} else {
-		filterString = "(objectClass=inetOrgPerson)"
-	}
-
-	// Create paging control
-	pagingControl := ldap.NewControlPaging(p.Config.PageSize)
-
-	// If we have a pagination token from previous request, decode it
-	if req.Cursor != nil {
-		cookie, err := base64.StdEncoding.DecodeString(*req.Cursor)
-		if err != nil {
-			return nil, fmt.Errorf("invalid cursor: %w", err)
-		}
-		// Set the cookie in the paging control
-		pagingControl.SetCookie(cookie)
+		filterString = schema.UserFilter
 	}
 
 	// Search filter for users
@@ -72,43 +54,22 @@ func (p *Provider) ListAccounts(ctx context.Context, req diragentapi.DirAgentLis
 		0,
 		false,
 		filterString, // Filter for user objects
-		attributes,
-		[]ldap.Control{pagingControl},
+		userAttributes(schema),
+		nil,
 	)
 
-	result, err := client.Search(searchRequest)
+	result, nextCursor, err := client.SearchPage(searchRequest, p.Config.PageSize, lo.FromPtr(req.Cursor))
 	if err != nil {
 		return nil, fmt.Errorf("error fetching users: %w", err)
 	}
 
 	rv := diragentapi.DirAgentListAccountsResponse{}
 	for _, entry := range result.Entries {
-		externalIDs := []string{}
-		if entry.GetAttributeValue("mail") != "" {
-			externalIDs = append(externalIDs, entry.GetAttributeValue("mail"))
-		}
-		if entry.GetAttributeValue("uid") != "" {
-			externalIDs = append(externalIDs, entry.GetAttributeValue("uid"))
-		}
-		modifyTime, _ := time.Parse(timeFormat, entry.GetAttributeValue("modifyTimestamp"))
-		account := diragentapi.DirAgentAccount{
-			ImmutableID: entry.GetAttributeValue("entryUUID"),
-			IDs:         externalIDs,
-			Name:        entry.GetAttributeValue("cn"),
-			UpdatedAt:   &modifyTime,
-		}
-
-		rv.Accounts = append(rv.Accounts, account)
+		rv.Accounts = append(rv.Accounts, p.account(schema, entry))
 	}
 
-	// Get the paging response to set up the next page
-	for _, control := range result.Controls {
-		if pagingResult, ok := control.(*ldap.ControlPaging); ok {
-			if len(pagingResult.Cookie) > 0 {
-				rv.NextCursor = lo.ToPtr(base64.StdEncoding.EncodeToString(pagingResult.Cookie))
-			}
-			break
-		}
+	if nextCursor != "" {
+		rv.NextCursor = &nextCursor
 	}
 
 	return &rv, nil
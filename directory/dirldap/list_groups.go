@@ -16,7 +16,6 @@ package dirldap
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
 
 	"github.com/go-ldap/ldap/v3"
@@ -32,23 +31,16 @@ func (p *Provider) ListGroups(ctx context.Context, req diragentapi.DirAgentListG
 		return nil, fmt.Errorf("could not get client from provider: %w", err)
 	}
 
+	schema, err := p.schema()
+	if err != nil {
+		return nil, err
+	}
+
 	var namePrefix string
 	if req.NamePrefix != nil {
 		namePrefix = *req.NamePrefix
 	}
 
-	pagingControl := ldap.NewControlPaging(p.Config.PageSize)
-
-	// If we have a pagination token from previous request, decode it
-	if req.Cursor != nil {
-		cookie, err := base64.StdEncoding.DecodeString(*req.Cursor)
-		if err != nil {
-			return nil, fmt.Errorf("invalid cursor: %w", err)
-		}
-		// Set the cookie in the paging control
-		pagingControl.SetCookie(cookie)
-	}
-
 	groupSearchRequest := ldap.NewSearchRequest(
 		p.Config.BaseDN, // Use the base DN instead of the prefix directly
 		ldap.ScopeWholeSubtree,
@@ -56,36 +48,24 @@ func (p *Provider) ListGroups(ctx context.Context, req diragentapi.DirAgentListG
 		0,
 		0,
 		false,
-		fmt.Sprintf("(&(objectClass=group)(dn=%s*))", namePrefix), // Search for groups where CN starts with prefix
-		[]string{"entryUUID", "dn"},
-		[]ldap.Control{pagingControl},
+		andFilter(schema.GroupFilter, prefixFilter(schema.GroupNameAttribute, namePrefix)), // Search for groups whose name starts with prefix
+		[]string{schema.ImmutableIDAttribute, schema.GroupNameAttribute},
+		nil,
 	)
 
-	groupResult, err := client.Search(groupSearchRequest)
+	groupResult, nextCursor, err := client.SearchPage(groupSearchRequest, p.Config.PageSize, lo.FromPtr(req.Cursor))
 	if err != nil {
-		return nil, fmt.Errorf("error fetching group %s: %w", *req.NamePrefix, err)
+		return nil, fmt.Errorf("error fetching groups starting with %q: %w", namePrefix, err)
 	}
 
 	userGroups := []diragentapi.DirAgentGroup{}
-	if len(groupResult.Entries) > 0 {
-		for _, groupEntry := range groupResult.Entries {
-			userGroups = append(userGroups, diragentapi.DirAgentGroup{
-				ImmutableID: groupEntry.GetAttributeValue("entryUUID"),
-				Name:        groupEntry.GetAttributeValue("dn"),
-				Kind:        "group",
-			})
-		}
+	for _, groupEntry := range groupResult.Entries {
+		userGroups = append(userGroups, group(schema, groupEntry))
 	}
 
 	rv := diragentapi.DirAgentListGroupsResponse{Groups: userGroups}
-	// Get the paging response to set up the next page
-	for _, control := range groupResult.Controls {
-		if pagingResult, ok := control.(*ldap.ControlPaging); ok {
-			if len(pagingResult.Cookie) > 0 {
-				rv.NextCursor = lo.ToPtr(base64.StdEncoding.EncodeToString(pagingResult.Cookie))
-			}
-			break
-		}
+	if nextCursor != "" {
+		rv.NextCursor = &nextCursor
 	}
 
 	return &rv, nil
@@ -17,17 +17,16 @@ package dirldap
 import (
 	"context"
 	"fmt"
+	"log"
 	"strconv"
 
 	"github.com/go-ldap/ldap/v3"
 	"github.com/samber/lo"
-	"github.com/sethvargo/go-password/password"
 
 	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/directory/pwgen"
 )
 
-const defaultPasswordLength = 6
-
 // performOperationGetTemporaryPassword will generate and set a temporary password required to be changed on first login.
 func (p *Provider) performOperationGetTemporaryPassword(ctx context.Context, req diragentapi.DirAgentPerformOperationRequest) (*diragentapi.DirAgentPerformOperationResponse, error) {
 	client, err := p.client()
@@ -39,111 +38,127 @@ func (p *Provider) performOperationGetTemporaryPassword(ctx context.Context, req
 		return &diragentapi.DirAgentPerformOperationResponse{}, nil
 	}
 
-	searchRequest := ldap.NewSearchRequest(
-		p.Config.BaseDN,
-		ldap.ScopeWholeSubtree,
-		ldap.NeverDerefAliases,
-		0,
-		0,
-		false,
-		fmt.Sprintf("(entryUUID=%s)", req.AccountImmutableID), // Filter for user objects
-		[]string{
-			"pwdPolicySubentry", // Points to the specific password policy entry
-		},
-		nil,
-	)
-
-	dnResult, err := client.Search(searchRequest)
+	schema, err := p.schema()
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch user: %w", err)
+		return nil, err
 	}
 
-	if dnResult.Entries != nil && len(dnResult.Entries) != 1 {
-		return nil, fmt.Errorf("expected exactly one result, got %d", len(dnResult.Entries))
+	if isActiveDirectory(schema) {
+		return p.assignADTemporaryPassword(client, schema, req.AccountImmutableID)
 	}
 
-	entry := dnResult.Entries[0]
-	var pwdMinLength int
-	// First, get the password policy DN from the user entry
-	policyDN := entry.GetAttributeValue("pwdPolicySubentry")
-	// If the user does not have a pwd policy associated or a default configured,
-	// we generate a password based on a fixed length.
-	if policyDN == "" && p.Config.DefaultPasswordPolicyDN == "" {
-		pwdMinLength = defaultPasswordLength
-	} else {
-		if policyDN == "" {
-			policyDN = p.Config.DefaultPasswordPolicyDN
-		}
+	entry, err := p.findUser(client, schema, req.AccountImmutableID, []string{
+		"pwdPolicySubentry", // Points to the specific password policy entry
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// Create a new search request for the password policy entry
-		policySearchRequest := ldap.NewSearchRequest(
-			policyDN,             // Use the policy DN directly
-			ldap.ScopeBaseObject, // We want just this entry
-			ldap.NeverDerefAliases,
-			0,
-			0,
-			false,
-			"(objectClass=*)", // Get the policy entry
-			[]string{
-				"pwdMinLength",
-				"pwdAllowUserChange",
-				"pwdMaxFailure",           // Max login attempts
-				"pwdLockoutDuration",      // How long account stays locked
-				"pwdFailureCountInterval", // Time window for counting failures
-			},
-			nil,
-		)
-
-		policyResult, err := client.Search(policySearchRequest)
-		if err != nil {
-			return nil, fmt.Errorf("failed to fetch password policy: %w", err)
-		}
+	policy, err := p.passwordPolicy(client, entry)
+	if err != nil {
+		return nil, err
+	}
 
-		if len(policyResult.Entries) != 1 {
-			return nil, fmt.Errorf("expected exactly one policy entry, got %d", len(policyResult.Entries))
-		}
+	tempPassword, err := pwgen.Generate(policy, pwgen.Options(p.Config.PasswordGeneration))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate password: %w", err)
+	}
 
-		policyEntry := policyResult.Entries[0]
-		pwdMinLengthStr := policyEntry.GetAttributeValue("pwdMinLength")
-		if pwdMinLengthStr == "" {
-			return nil, fmt.Errorf("failed to fetch password policy: missing pwdMinLength attribute")
-		}
+	if err := p.setPassword(client, entry.DN, tempPassword); err != nil {
+		return nil, err
+	}
 
-		pwdMinLength, err = strconv.Atoi(pwdMinLengthStr)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse pwdMinLength: %w", err)
+	// Force a password change on next login. The password has changed
+	// already, so the user must get it even if this fails.
+	if schema.PasswordResetAttribute != "" {
+		resetModify := ldap.NewModifyRequest(entry.DN, []ldap.Control{ldap.NewControlBeheraPasswordPolicy()})
+		resetModify.Replace(schema.PasswordResetAttribute, []string{"TRUE"})
+		if err := client.Modify(resetModify); err != nil {
+			log.Printf("WARNING: the password of %s was changed, but %s could not be set, so the user will not have to change it: %v",
+				entry.DN, schema.PasswordResetAttribute, err)
 		}
 	}
 
-	// Create modify request for password change
-	passwordModify := ldap.NewModifyRequest(entry.DN, nil)
+	response := &diragentapi.DirAgentPerformOperationResponse{
+		TemporaryPassword: &tempPassword,
+	}
+	return response, nil
+}
 
-	tempPassword, err := password.Generate(pwdMinLength, 1, 1, false, false)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate password: %w", err)
+// passwordPolicy returns the password policy that applies to a user entry,
+// which was fetched with pwdPolicySubentry.
+//
+// pwdInHistory and pwdMinAge are not read: a random password is as good as
+// never in the history, and a reset refused because the password was changed
+// too recently is reported by the password policy control, see
+// passwordPolicyError.
+func (p *Provider) passwordPolicy(client Client, entry *ldap.Entry) (pwgen.Policy, error) {
+	// First, get the password policy DN from the user entry
+	policyDN := entry.GetAttributeValue("pwdPolicySubentry")
+	if policyDN == "" {
+		policyDN = p.Config.DefaultPasswordPolicyDN
+	}
+	// If the user does not have a pwd policy associated or a default configured,
+	// only the configured floor length applies.
+	if policyDN == "" {
+		return pwgen.Policy{}, nil
 	}
 
-	// Hash the password using SSHA (Salted SHA1) - recommended for OpenLDAP
-	passwordModify.Replace("userPassword", []string{tempPassword})
+	// Create a new search request for the password policy entry
+	policySearchRequest := ldap.NewSearchRequest(
+		policyDN,             // Use the policy DN directly
+		ldap.ScopeBaseObject, // We want just this entry
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		"(objectClass=*)", // Get the policy entry
+		[]string{
+			"pwdMinLength",
+			"pwdMaxLength",
+			"pwdCheckQuality", // Whether the server checks password quality
+		},
+		nil,
+	)
 
-	// Set pwdReset to TRUE to force password change on next login
-	passwordModify.Replace("pwdReset", []string{"TRUE"})
+	policyResult, err := client.Search(policySearchRequest)
+	if err != nil {
+		return pwgen.Policy{}, fmt.Errorf("failed to fetch password policy: %w", err)
+	}
 
-	// Create and add the password policy control
-	pPolicyControl := ldap.NewControlString(
-		"1.3.6.1.4.1.42.2.27.8.5.1", // Password Policy Control OID
-		true,                        // Criticality set to true
-		"",                          // No control value needed
-	)
-	passwordModify.Controls = append(passwordModify.Controls, pPolicyControl)
+	if len(policyResult.Entries) != 1 {
+		return pwgen.Policy{}, fmt.Errorf("expected exactly one policy entry, got %d", len(policyResult.Entries))
+	}
 
-	err = client.Modify(passwordModify)
+	policyEntry := policyResult.Entries[0]
+	var policy pwgen.Policy
+	if policy.MinLength, err = intAttributeValue(policyEntry, "pwdMinLength"); err != nil {
+		return pwgen.Policy{}, err
+	}
+	if policy.MaxLength, err = intAttributeValue(policyEntry, "pwdMaxLength"); err != nil {
+		return pwgen.Policy{}, err
+	}
+	checkQuality, err := intAttributeValue(policyEntry, "pwdCheckQuality")
 	if err != nil {
-		return nil, fmt.Errorf("failed to modify password and set reset flag: %w", err)
+		return pwgen.Policy{}, err
 	}
+	if checkQuality > 0 {
+		// Quality checking modules commonly require three character classes
+		policy.MinClasses = 3
+	}
+	return policy, nil
+}
 
-	response := &diragentapi.DirAgentPerformOperationResponse{
-		TemporaryPassword: &tempPassword,
+// intAttributeValue returns the integer value of an attribute, or zero if it
+// is not set.
+func intAttributeValue(entry *ldap.Entry, attribute string) (int, error) {
+	value := entry.GetAttributeValue(attribute)
+	if value == "" {
+		return 0, nil
 	}
-	return response, nil
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %w", attribute, err)
+	}
+	return n, nil
 }
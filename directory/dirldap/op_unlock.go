@@ -32,33 +32,19 @@ func (p *Provider) performOperationUnlock(ctx context.Context, req diragentapi.D
 		return nil, fmt.Errorf("could not get client from provider: %w", err)
 	}
 
-	unlockAttribute := "pwdAccountLockedTime"
-	searchRequest := ldap.NewSearchRequest(
-		p.Config.BaseDN,
-		ldap.ScopeWholeSubtree,
-		ldap.NeverDerefAliases,
-		0,
-		0,
-		false,
-		fmt.Sprintf("(entryUUID=%s)", req.AccountImmutableID), // Filter for user objects
-		[]string{
-			unlockAttribute,
-		},
-		nil,
-	)
-
-	result, err := client.Search(searchRequest)
+	schema, err := p.schema()
 	if err != nil {
-		return nil, fmt.Errorf("failed to search for user: %w", err)
+		return nil, err
 	}
 
-	if result.Entries != nil && len(result.Entries) != 1 {
-		return nil, fmt.Errorf("expected exactly one result, got %d", len(result.Entries))
+	unlockAttribute := schema.LockAttribute
+	userEntry, err := p.findUser(client, schema, req.AccountImmutableID, []string{unlockAttribute})
+	if err != nil {
+		return nil, err
 	}
 
-	userEntry := result.Entries[0]
 	accountLockTime := userEntry.GetAttributeValue(unlockAttribute)
-	if accountLockTime == "" {
+	if accountLockTime == "" || accountLockTime == schema.UnlockValue {
 		return nil, directory.CodedError{
 			Code:    diragentapi.UnsupportedAccountState,
 			Message: "account is not locked",
@@ -71,13 +57,17 @@ func (p *Provider) performOperationUnlock(ctx context.Context, req diragentapi.D
 
 	modify := ldap.NewModifyRequest(userEntry.DN, nil)
 
-	// Delete the pwdAccountLockedTime attribute
-	modify.Delete(unlockAttribute, []string{})
+	// Clear the lock attribute
+	if schema.UnlockValue != "" {
+		modify.Replace(unlockAttribute, []string{schema.UnlockValue})
+	} else {
+		modify.Delete(unlockAttribute, []string{})
+	}
 
 	// Add ppolicy control to get additional information
 	pPolicyControl := ldap.NewControlString(
 		"1.3.6.1.4.1.42.2.27.8.5.1", // Password Policy Control OID
-		true,                        // Criticality
+		false,                       // Not critical, so servers without ppolicy ignore it
 		"",                          // No value needed
 	)
 	modify.Controls = append(modify.Controls, pPolicyControl)
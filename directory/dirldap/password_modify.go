@@ -0,0 +1,194 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirldap
+
+import (
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec // SSHA is defined in terms of SHA-1
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"golang.org/x/crypto/argon2"
+
+	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/directory"
+)
+
+// passwordModifyOID identifies the password modify extended operation (RFC 3062).
+const passwordModifyOID = "1.3.6.1.4.1.4203.1.11.1"
+
+// Values of config.LDAPConfig.PasswordHash.
+const (
+	passwordHashNone   = "none"
+	passwordHashSSHA   = "ssha"
+	passwordHashArgon2 = "argon2"
+)
+
+// Parameters for {ARGON2} hashes, matching the defaults of the OpenLDAP
+// argon2 module.
+const (
+	argon2Time    = 2
+	argon2Memory  = 64 * 1024
+	argon2Threads = 1
+	argon2KeyLen  = 32
+	argon2SaltLen = 16
+)
+
+// setPassword sets the password of the entry at dn. The server hashes the
+// password itself if it supports the password modify extended operation.
+// Otherwise userPassword is replaced, hashed as configured. Either way the
+// password policy control is sent, so that a rejected password can be
+// explained.
+func (p *Provider) setPassword(client Client, dn string, newPassword string) error {
+	supported, err := supportsExtension(client, passwordModifyOID)
+	if err != nil {
+		return err
+	}
+
+	if supported {
+		// ldap.PasswordModifyRequest cannot carry controls, so the request
+		// is encoded here.
+		value := ber.NewSequence("Password Modify Request")
+		value.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 0, dn, "User Identity"))
+		value.AppendChild(ber.NewString(ber.ClassContext, ber.TypePrimitive, 2, newPassword, "New Password"))
+		requestValue := ber.Encode(ber.ClassContext, ber.TypePrimitive, 1, nil, "Extended Request Value")
+		requestValue.AppendChild(value)
+
+		request := ldap.NewExtendedRequest(passwordModifyOID, requestValue)
+		request.Controls = []ldap.Control{ldap.NewControlBeheraPasswordPolicy()}
+		if _, err := client.Extended(request); err != nil {
+			return passwordPolicyError(err)
+		}
+		return nil
+	}
+
+	value, err := hashPassword(p.Config.PasswordHash, newPassword)
+	if err != nil {
+		return err
+	}
+	modify := ldap.NewModifyRequest(dn, []ldap.Control{ldap.NewControlBeheraPasswordPolicy()})
+	modify.Replace("userPassword", []string{value})
+	if err := client.Modify(modify); err != nil {
+		return passwordPolicyError(err)
+	}
+	return nil
+}
+
+// supportsExtension returns true if the root DSE lists the extended
+// operation oid.
+func supportsExtension(client Client, oid string) (bool, error) {
+	result, err := client.Search(ldap.NewSearchRequest(
+		"", // Empty DN for Root DSE
+		ldap.ScopeBaseObject,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		"(objectClass=*)",
+		[]string{"supportedExtension"},
+		nil,
+	))
+	if err != nil {
+		return false, fmt.Errorf("failed to read root DSE: %w", err)
+	}
+	for _, entry := range result.Entries {
+		for _, extension := range entry.GetAttributeValues("supportedExtension") {
+			if extension == oid {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// hashPassword returns the userPassword value for pw using scheme. The
+// password is only written as is if that was asked for explicitly.
+func hashPassword(scheme string, pw string) (string, error) {
+	switch strings.ToLower(scheme) {
+	case passwordHashNone:
+		return pw, nil
+	case passwordHashSSHA:
+		salt := make([]byte, 8)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		sum := sha1.Sum(append([]byte(pw), salt...)) //nolint:gosec // SSHA is defined in terms of SHA-1
+		return "{SSHA}" + base64.StdEncoding.EncodeToString(append(sum[:], salt...)), nil
+	case passwordHashArgon2:
+		salt := make([]byte, argon2SaltLen)
+		if _, err := rand.Read(salt); err != nil {
+			return "", err
+		}
+		key := argon2.IDKey([]byte(pw), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+		return fmt.Sprintf("{ARGON2}$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, argon2Memory, argon2Time, argon2Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key)), nil
+	case "":
+		return "", directory.CodedError{
+			Code: diragentapi.ConfigurationError,
+			Message: fmt.Sprintf("the server does not support the password modify operation, so passwords are written to userPassword; "+
+				"set the password hash to %q or %q, or to %q if the server hashes userPassword itself", passwordHashSSHA, passwordHashArgon2, passwordHashNone),
+		}
+	default:
+		return "", directory.CodedError{
+			Code:    diragentapi.ConfigurationError,
+			Message: fmt.Sprintf("unknown password hash %q, expected %q, %q or %q", scheme, passwordHashSSHA, passwordHashArgon2, passwordHashNone),
+		}
+	}
+}
+
+// passwordPolicyError converts the password policy response control of a
+// failed request, if there is one, into a coded error.
+func passwordPolicyError(err error) error {
+	var ldapErr *ldap.Error
+	if !errors.As(err, &ldapErr) || ldapErr.Packet == nil || len(ldapErr.Packet.Children) < 3 {
+		return fmt.Errorf("failed to set password: %w", err)
+	}
+
+	for _, child := range ldapErr.Packet.Children[2].Children {
+		control, decodeErr := ldap.DecodeControl(child)
+		if decodeErr != nil {
+			continue
+		}
+		policy, ok := control.(*ldap.ControlBeheraPasswordPolicy)
+		if !ok || policy.Error < 0 {
+			continue
+		}
+
+		var code diragentapi.DirAgentErrorCode
+		switch policy.Error {
+		case ldap.BeheraPasswordModNotAllowed, ldap.BeheraMustSupplyOldPassword:
+			code = diragentapi.PermissionDenied
+		case ldap.BeheraAccountLocked, ldap.BeheraPasswordTooYoung:
+			code = diragentapi.UnsupportedAccountState
+		case ldap.BeheraInsufficientPasswordQuality, ldap.BeheraPasswordTooShort, ldap.BeheraPasswordInHistory:
+			// the generated password does not satisfy the policy
+			code = diragentapi.ConfigurationError
+		default:
+			code = diragentapi.InternalError
+		}
+		return directory.CodedError{
+			Code:    code,
+			Message: fmt.Sprintf("password policy: %s", policy.ErrorString),
+		}
+	}
+
+	return fmt.Errorf("failed to set password: %w", err)
+}
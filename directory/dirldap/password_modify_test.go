@@ -0,0 +1,187 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirldap
+
+import (
+	"context"
+	"crypto/sha1" //nolint:gosec // SSHA is defined in terms of SHA-1
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/samber/lo"
+
+	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/directory"
+	"github.com/nametaginc/cli/internal/config"
+)
+
+const aliceDN = "uid=alice,ou=people,dc=example,dc=com"
+
+// newPasswordServer returns a server with alice, whose password policy
+// requires 16 characters. If passwordModify is true, the server supports
+// the password modify extended operation.
+func newPasswordServer(t *testing.T, passwordModify bool) *testServer {
+	rootDSE := map[string][]string{"objectClass": {"top"}}
+	if passwordModify {
+		rootDSE["supportedExtension"] = []string{passwordModifyOID}
+	}
+	return newTestServer(t,
+		testEntry("", rootDSE),
+		testEntry(aliceDN, map[string][]string{
+			"objectClass":       {"inetOrgPerson"},
+			"uid":               {"alice"},
+			"cn":                {"Alice"},
+			"entryUUID":         {"uuid-alice"},
+			"pwdPolicySubentry": {"cn=default,ou=policies,dc=example,dc=com"},
+		}),
+		testEntry("cn=default,ou=policies,dc=example,dc=com", map[string][]string{
+			"objectClass":     {"pwdPolicy"},
+			"pwdMinLength":    {"16"},
+			"pwdCheckQuality": {"2"},
+		}),
+	)
+}
+
+func getTemporaryPassword(p *Provider, immutableID string) (string, error) {
+	resp, err := p.PerformOperation(context.Background(), diragentapi.DirAgentPerformOperationRequest{
+		Operation:          diragentapi.GetTemporaryPassword,
+		AccountImmutableID: immutableID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return lo.FromPtr(resp.TemporaryPassword), nil
+}
+
+func TestTemporaryPasswordWithPasswordModify(t *testing.T) {
+	server := newPasswordServer(t, true)
+	p := newTestProvider(t, server, 1, config.LDAPSchema{})
+
+	password, err := getTemporaryPassword(p, "uuid-alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(password) < 16 {
+		t.Errorf("password %q is shorter than the policy's 16 characters", password)
+	}
+
+	entry := server.Entry(aliceDN)
+	if got := entry.GetAttributeValue("userPassword"); got != password {
+		t.Errorf("userPassword = %q, want %q", got, password)
+	}
+	if got := entry.GetAttributeValue("pwdReset"); got != "TRUE" {
+		t.Errorf("pwdReset = %q, want TRUE", got)
+	}
+	if want := [][]string{{ldap.ControlTypeBeheraPasswordPolicy}}; !slices.EqualFunc(server.ExtendedControls, want, slices.Equal) {
+		t.Errorf("extended request controls = %v, want %v", server.ExtendedControls, want)
+	}
+}
+
+func TestPasswordModifyPolicyError(t *testing.T) {
+	server := newPasswordServer(t, true)
+	server.PasswordMinLength = 100
+	p := newTestProvider(t, server, 1, config.LDAPSchema{})
+
+	// The server explains the failure in the password policy response
+	// control, because the request control was sent.
+	_, err := getTemporaryPassword(p, "uuid-alice")
+	var codedErr directory.CodedError
+	if !errors.As(err, &codedErr) || codedErr.Code != diragentapi.ConfigurationError ||
+		!strings.Contains(codedErr.Message, "Password is too short for policy") {
+		t.Errorf("got %v, want a configuration error about the password length", err)
+	}
+}
+
+func TestTemporaryPasswordResetAttribute(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		schema    config.LDAPSchema
+		reject    []string
+		wantReset string
+	}{
+		{"openldap", config.LDAPSchema{}, nil, "TRUE"},
+		// the password is returned even though the reset flag failed
+		{"no pwdReset in schema", config.LDAPSchema{}, []string{"pwdReset"}, ""},
+		// 389ds expires passwords set by an administrator on its own
+		{"389ds", config.LDAPSchema{Preset: "389ds", ImmutableIDAttribute: "entryUUID"}, []string{"pwdReset"}, ""},
+	} {
+		server := newPasswordServer(t, true)
+		server.RejectAttributes = test.reject
+		p := newTestProvider(t, server, 1, test.schema)
+
+		password, err := getTemporaryPassword(p, "uuid-alice")
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		entry := server.Entry(aliceDN)
+		if got := entry.GetAttributeValue("userPassword"); got != password {
+			t.Errorf("%s: userPassword = %q, want %q", test.name, got, password)
+		}
+		if got := entry.GetAttributeValue("pwdReset"); got != test.wantReset {
+			t.Errorf("%s: pwdReset = %q, want %q", test.name, got, test.wantReset)
+		}
+	}
+}
+
+func TestTemporaryPasswordWithoutPasswordModify(t *testing.T) {
+	for _, test := range []struct {
+		hash    string
+		check   func(value, password string) bool
+		wantErr bool
+	}{
+		{"", nil, true},
+		{"none", func(value, password string) bool { return value == password }, false},
+		{"ssha", checkSSHA, false},
+		{"argon2", func(value, _ string) bool { return strings.HasPrefix(value, "{ARGON2}$argon2id$v=19$") }, false},
+		{"md5", nil, true},
+	} {
+		server := newPasswordServer(t, false)
+		p := newTestProvider(t, server, 1, config.LDAPSchema{})
+		p.Config.PasswordHash = test.hash
+
+		password, err := getTemporaryPassword(p, "uuid-alice")
+		if test.wantErr {
+			var codedErr directory.CodedError
+			if !errors.As(err, &codedErr) || codedErr.Code != diragentapi.ConfigurationError {
+				t.Errorf("hash %q: got %v, want a configuration error", test.hash, err)
+			}
+			if got := server.Entry(aliceDN).GetAttributeValue("userPassword"); got != "" {
+				t.Errorf("hash %q: userPassword was set to %q", test.hash, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("hash %q: %v", test.hash, err)
+		}
+		if got := server.Entry(aliceDN).GetAttributeValue("userPassword"); !test.check(got, password) {
+			t.Errorf("hash %q: userPassword = %q does not match the password", test.hash, got)
+		}
+	}
+}
+
+// checkSSHA returns true if value is the SSHA hash of password.
+func checkSSHA(value, password string) bool {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, "{SSHA}"))
+	if err != nil || !strings.HasPrefix(value, "{SSHA}") || len(raw) <= sha1.Size {
+		return false
+	}
+	sum := sha1.Sum(append([]byte(password), raw[sha1.Size:]...)) //nolint:gosec // SSHA is defined in terms of SHA-1
+	return string(sum[:]) == string(raw[:sha1.Size])
+}
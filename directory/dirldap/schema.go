@@ -0,0 +1,388 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirldap
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/samber/lo"
+
+	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/directory"
+	"github.com/nametaginc/cli/internal/config"
+)
+
+// Values of config.LDAPSchema.ImmutableIDType.
+const (
+	immutableIDTypeString = "string"
+	immutableIDTypeGUID   = "guid"
+)
+
+// schemaPresets are the attribute mappings for common directory servers.
+var schemaPresets = map[string]config.LDAPSchema{
+	"openldap": {
+		UserFilter:               "(objectClass=inetOrgPerson)",
+		GroupFilter:              "(objectClass=groupOfNames)",
+		ImmutableIDAttribute:     "entryUUID",
+		ImmutableIDType:          immutableIDTypeString,
+		IDAttributes:             []string{"mail", "uid"},
+		NameAttribute:            "cn",
+		GroupNameAttribute:       "cn",
+		MemberOfAttribute:        "memberOf",
+		ModifyTimestampAttribute: "modifyTimestamp",
+		TimestampFormat:          timeFormat,
+		PasswordResetAttribute:   "pwdReset",
+		LockAttribute:            "pwdAccountLockedTime",
+	},
+	"389ds": {
+		UserFilter:               "(objectClass=inetOrgPerson)",
+		GroupFilter:              "(|(objectClass=groupOfNames)(objectClass=groupOfUniqueNames))",
+		ImmutableIDAttribute:     "nsUniqueId",
+		ImmutableIDType:          immutableIDTypeString,
+		IDAttributes:             []string{"mail", "uid"},
+		NameAttribute:            "cn",
+		GroupNameAttribute:       "cn",
+		MemberOfAttribute:        "memberOf",
+		ModifyTimestampAttribute: "modifyTimestamp",
+		TimestampFormat:          timeFormat,
+		LockAttribute:            "accountUnlockTime",
+	},
+	"freeipa": {
+		UserFilter:               "(objectClass=inetOrgPerson)",
+		GroupFilter:              "(objectClass=ipaUserGroup)",
+		ImmutableIDAttribute:     "ipaUniqueID",
+		ImmutableIDType:          immutableIDTypeString,
+		IDAttributes:             []string{"mail", "uid", "krbPrincipalName"},
+		NameAttribute:            "cn",
+		GroupNameAttribute:       "cn",
+		MemberOfAttribute:        "memberOf",
+		ModifyTimestampAttribute: "modifyTimestamp",
+		TimestampFormat:          timeFormat,
+		LockAttribute:            "krbLoginFailedCount",
+		UnlockValue:              "0",
+	},
+	schemaPresetActiveDirectory: {
+		UserFilter:               "(&(objectCategory=person)(objectClass=user))",
+		GroupFilter:              "(objectClass=group)",
+		ImmutableIDAttribute:     "objectGUID",
+		ImmutableIDType:          immutableIDTypeGUID,
+		IDAttributes:             []string{"mail", "sAMAccountName", "userPrincipalName"},
+		NameAttribute:            "displayName",
+		GroupNameAttribute:       "cn",
+		MemberOfAttribute:        "memberOf",
+		ModifyTimestampAttribute: "whenChanged",
+		TimestampFormat:          "20060102150405.0Z",
+		LockAttribute:            "lockoutTime",
+		UnlockValue:              "0",
+	},
+}
+
+const defaultSchemaPreset = "openldap"
+
+// Values of config.LDAPSchema.GroupMembership.
+const (
+	groupMembershipMemberOf = "memberOf"
+	groupMembershipMember   = "member"
+)
+
+// schema returns the attribute mapping to use: the configured preset with
+// any explicitly configured attributes taking precedence.
+func (p *Provider) schema() (*config.LDAPSchema, error) {
+	if p._schema != nil {
+		return p._schema, nil
+	}
+
+	configured := p.Config.Schema
+	presetName := strings.ToLower(configured.Preset)
+	if presetName == "" {
+		presetName = defaultSchemaPreset
+	}
+	preset, ok := schemaPresets[presetName]
+	if !ok {
+		return nil, fmt.Errorf("unknown LDAP schema preset %q, expected one of %s",
+			configured.Preset, strings.Join(schemaPresetNames(), ", "))
+	}
+
+	schema := preset
+	schema.Preset = presetName
+	override := func(dst *string, value string) {
+		if value != "" {
+			*dst = value
+		}
+	}
+	override(&schema.UserFilter, configured.UserFilter)
+	override(&schema.GroupFilter, configured.GroupFilter)
+	override(&schema.ImmutableIDAttribute, configured.ImmutableIDAttribute)
+	override(&schema.ImmutableIDType, configured.ImmutableIDType)
+	override(&schema.NameAttribute, configured.NameAttribute)
+	override(&schema.GroupNameAttribute, configured.GroupNameAttribute)
+	override(&schema.MemberOfAttribute, configured.MemberOfAttribute)
+	override(&schema.ModifyTimestampAttribute, configured.ModifyTimestampAttribute)
+	override(&schema.TimestampFormat, configured.TimestampFormat)
+	override(&schema.PasswordResetAttribute, configured.PasswordResetAttribute)
+	override(&schema.LockAttribute, configured.LockAttribute)
+	override(&schema.UnlockValue, configured.UnlockValue)
+	override(&schema.GroupMembership, configured.GroupMembership)
+	override(&schema.MemberAttribute, configured.MemberAttribute)
+	override(&schema.DynamicGroupFilter, configured.DynamicGroupFilter)
+	override(&schema.MemberURLAttribute, configured.MemberURLAttribute)
+	schema.NestedGroups = schema.NestedGroups || configured.NestedGroups
+	if len(configured.IDAttributes) > 0 {
+		schema.IDAttributes = configured.IDAttributes
+	}
+
+	if schema.GroupMembership == "" {
+		schema.GroupMembership = groupMembershipMemberOf
+	}
+	if schema.MemberAttribute == "" {
+		schema.MemberAttribute = "member"
+	}
+	if schema.MemberURLAttribute == "" {
+		schema.MemberURLAttribute = "memberURL"
+	}
+
+	switch schema.GroupMembership {
+	case groupMembershipMemberOf, groupMembershipMember:
+	default:
+		return nil, fmt.Errorf("unknown LDAP group membership %q, expected %q or %q",
+			schema.GroupMembership, groupMembershipMemberOf, groupMembershipMember)
+	}
+
+	switch schema.ImmutableIDType {
+	case immutableIDTypeString, immutableIDTypeGUID:
+	default:
+		return nil, fmt.Errorf("unknown LDAP immutable ID type %q, expected %q or %q",
+			schema.ImmutableIDType, immutableIDTypeString, immutableIDTypeGUID)
+	}
+
+	if err := validateSchema(&schema); err != nil {
+		return nil, err
+	}
+
+	p._schema = &schema
+	return p._schema, nil
+}
+
+// validateSchema checks the filters and attribute names of a schema, which
+// are used to build filters.
+func validateSchema(schema *config.LDAPSchema) error {
+	if err := validateFilter("user filter", schema.UserFilter); err != nil {
+		return err
+	}
+	if err := validateFilter("group filter", schema.GroupFilter); err != nil {
+		return err
+	}
+	if schema.DynamicGroupFilter != "" {
+		if err := validateFilter("dynamic group filter", schema.DynamicGroupFilter); err != nil {
+			return err
+		}
+	}
+	if len(schema.IDAttributes) == 0 {
+		return fmt.Errorf("at least one ID attribute is required")
+	}
+	attributes := map[string]string{
+		"immutable ID attribute":     schema.ImmutableIDAttribute,
+		"name attribute":             schema.NameAttribute,
+		"group name attribute":       schema.GroupNameAttribute,
+		"member of attribute":        schema.MemberOfAttribute,
+		"modify timestamp attribute": schema.ModifyTimestampAttribute,
+		"lock attribute":             schema.LockAttribute,
+		"member attribute":           schema.MemberAttribute,
+		"member URL attribute":       schema.MemberURLAttribute,
+	}
+	for _, attribute := range schema.IDAttributes {
+		if err := validateAttributeName("ID attribute", attribute); err != nil {
+			return err
+		}
+	}
+	if schema.PasswordResetAttribute != "" {
+		attributes["password reset attribute"] = schema.PasswordResetAttribute
+	}
+	for _, name := range lo.Keys(attributes) {
+		if err := validateAttributeName(name, attributes[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func schemaPresetNames() []string {
+	names := lo.Keys(schemaPresets)
+	sort.Strings(names)
+	return names
+}
+
+// userAttributes returns the attributes to fetch for user entries, plus any
+// extra attributes.
+func userAttributes(schema *config.LDAPSchema, extra ...string) []string {
+	attributes := []string{
+		schema.ImmutableIDAttribute,
+		schema.NameAttribute,
+		schema.ModifyTimestampAttribute,
+	}
+	attributes = append(attributes, schema.IDAttributes...)
+	attributes = append(attributes, extra...)
+	return lo.Uniq(attributes)
+}
+
+// immutableID returns the immutable ID of an entry as a string.
+func immutableID(schema *config.LDAPSchema, entry *ldap.Entry) string {
+	if schema.ImmutableIDType == immutableIDTypeGUID {
+		return formatGUID(entry.GetRawAttributeValue(schema.ImmutableIDAttribute))
+	}
+	return entry.GetAttributeValue(schema.ImmutableIDAttribute)
+}
+
+// immutableIDFilter returns a filter that matches the entry with the given
+// immutable ID.
+func immutableIDFilter(schema *config.LDAPSchema, id string) (string, error) {
+	if schema.ImmutableIDType == immutableIDTypeGUID {
+		raw, err := parseGUID(id)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("(%s=%s)", schema.ImmutableIDAttribute, escapeBytes(raw)), nil
+	}
+	return equalityFilter(schema.ImmutableIDAttribute, id), nil
+}
+
+// identifierFilter returns a filter that matches entries where any of the
+// identifier attributes has the given value.
+func identifierFilter(schema *config.LDAPSchema, id string) string {
+	return orFilter(lo.Map(schema.IDAttributes, func(attribute string, _ int) string {
+		return equalityFilter(attribute, id)
+	})...)
+}
+
+// escapeBytes escapes every byte of a binary value for use in a filter.
+func escapeBytes(raw []byte) string {
+	var b strings.Builder
+	for _, c := range raw {
+		fmt.Fprintf(&b, "\\%02x", c)
+	}
+	return b.String()
+}
+
+// account returns the account for a user entry, without groups.
+func (p *Provider) account(schema *config.LDAPSchema, entry *ldap.Entry) diragentapi.DirAgentAccount {
+	externalIDs := []string{}
+	for _, attribute := range schema.IDAttributes {
+		for _, value := range entry.GetAttributeValues(attribute) {
+			if value != "" && !lo.Contains(externalIDs, value) {
+				externalIDs = append(externalIDs, value)
+			}
+		}
+	}
+	account := diragentapi.DirAgentAccount{
+		ImmutableID: immutableID(schema, entry),
+		IDs:         externalIDs,
+		Name:        entry.GetAttributeValue(schema.NameAttribute),
+	}
+	if modifyTime, err := parseGeneralizedTime(entry.GetAttributeValue(schema.ModifyTimestampAttribute)); err == nil {
+		account.UpdatedAt = &modifyTime
+	}
+	return account
+}
+
+// group returns the group for a group entry, which was fetched with the
+// group name attribute. Groups without a name are named by their DN.
+func group(schema *config.LDAPSchema, entry *ldap.Entry) diragentapi.DirAgentGroup {
+	name, _ := lo.Coalesce(entry.GetAttributeValue(schema.GroupNameAttribute), entry.DN)
+	return diragentapi.DirAgentGroup{
+		ImmutableID: immutableID(schema, entry),
+		Name:        name,
+		Kind:        "group",
+	}
+}
+
+// parseGeneralizedTime parses an LDAP GeneralizedTime, which may have
+// fractional seconds and a numeric time zone.
+func parseGeneralizedTime(value string) (time.Time, error) {
+	return time.Parse("20060102150405Z0700", value)
+}
+
+// formatGUID formats a binary Active Directory GUID in its usual string form.
+// The first three fields are stored little-endian.
+func formatGUID(raw []byte) string {
+	if len(raw) != 16 {
+		return hex.EncodeToString(raw)
+	}
+	return fmt.Sprintf("%08x-%04x-%04x-%s-%s",
+		binary.LittleEndian.Uint32(raw[0:4]),
+		binary.LittleEndian.Uint16(raw[4:6]),
+		binary.LittleEndian.Uint16(raw[6:8]),
+		hex.EncodeToString(raw[8:10]),
+		hex.EncodeToString(raw[10:16]))
+}
+
+// parseGUID is the inverse of formatGUID.
+func parseGUID(id string) ([]byte, error) {
+	b, err := hex.DecodeString(strings.ReplaceAll(id, "-", ""))
+	if err != nil || len(b) != 16 {
+		return nil, fmt.Errorf("invalid GUID %q", id)
+	}
+	raw := make([]byte, 16)
+	binary.LittleEndian.PutUint32(raw[0:4], binary.BigEndian.Uint32(b[0:4]))
+	binary.LittleEndian.PutUint16(raw[4:6], binary.BigEndian.Uint16(b[4:6]))
+	binary.LittleEndian.PutUint16(raw[6:8], binary.BigEndian.Uint16(b[6:8]))
+	copy(raw[8:], b[8:])
+	return raw, nil
+}
+
+// findUser returns the user entry with the given immutable ID.
+func (p *Provider) findUser(client Client, schema *config.LDAPSchema, id string, attributes []string) (*ldap.Entry, error) {
+	idFilter, err := immutableIDFilter(schema, id)
+	if err != nil {
+		return nil, directory.CodedError{
+			Code:    diragentapi.AccountNotFound,
+			Message: err.Error(),
+		}
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		p.Config.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0,
+		0,
+		false,
+		andFilter(schema.UserFilter, idFilter),
+		attributes,
+		nil,
+	)
+
+	result, err := client.Search(searchRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for user: %w", err)
+	}
+
+	switch len(result.Entries) {
+	case 0:
+		return nil, directory.CodedError{
+			Code:    diragentapi.AccountNotFound,
+			Message: fmt.Sprintf("no account with immutable ID %q", id),
+		}
+	case 1:
+		return result.Entries[0], nil
+	default:
+		return nil, fmt.Errorf("expected exactly one result, got %d", len(result.Entries))
+	}
+}
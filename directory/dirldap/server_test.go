@@ -0,0 +1,527 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirldap
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// testServer is an in-process LDAP server for tests. It keeps its entries in
+// memory and speaks enough of the protocol for the provider: simple binds,
+// searches with the paged results control, modifies and the password modify
+// extended operation. Filters are evaluated on the server, so tests exercise
+// the filters the provider builds.
+type testServer struct {
+	t *testing.T
+
+	mu      sync.Mutex
+	entries []*ldap.Entry
+	conns   []net.Conn
+	dials   int
+
+	// PasswordMinLength, if set, makes password changes shorter than it fail
+	// with the passwordTooShort password policy error.
+	PasswordMinLength int
+
+	// RejectAttributes are attributes that the schema does not allow, so
+	// modifies of them fail.
+	RejectAttributes []string
+
+	// ExtendedControls are the OIDs of the controls sent with each extended
+	// request.
+	ExtendedControls [][]string
+}
+
+// newTestServer returns a server with the given entries. An entry with an
+// empty DN is the root DSE.
+func newTestServer(t *testing.T, entries ...*ldap.Entry) *testServer {
+	s := &testServer{t: t, entries: entries}
+	t.Cleanup(s.dropConnections)
+	return s
+}
+
+// testEntry returns an entry with attributes given as name and values pairs.
+func testEntry(dn string, attributes map[string][]string) *ldap.Entry {
+	return ldap.NewEntry(dn, attributes)
+}
+
+// dial connects to the server with an in-memory connection.
+func (s *testServer) dial() (*ldap.Conn, error) {
+	client, server := net.Pipe()
+	s.mu.Lock()
+	s.conns = append(s.conns, server)
+	s.dials++
+	s.mu.Unlock()
+	go s.serve(server)
+
+	conn := ldap.NewConn(client, false)
+	conn.Start()
+	return conn, nil
+}
+
+// dropConnections closes every connection, as a server restart would.
+func (s *testServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.conns = nil
+}
+
+// Entry returns the entry with dn, or nil.
+func (s *testServer) Entry(dn string) *ldap.Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entry(dn)
+}
+
+func (s *testServer) entry(dn string) *ldap.Entry {
+	for _, entry := range s.entries {
+		if normalizeDN(entry.DN) == normalizeDN(dn) {
+			return entry
+		}
+	}
+	return nil
+}
+
+// serve handles the requests of one connection. Paging cookies are only
+// valid on the connection that issued them, like on real servers.
+func (s *testServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	cookies := map[string]bool{}
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			if err != io.EOF && !strings.Contains(err.Error(), "closed") {
+				s.t.Logf("test server: %v", err)
+			}
+			return
+		}
+		if len(packet.Children) < 2 {
+			return
+		}
+		msgID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		var controls []*ber.Packet
+		if len(packet.Children) > 2 {
+			controls = packet.Children[2].Children
+		}
+
+		var responses []*ber.Packet
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			responses = append(responses, s.bind(op))
+		case ldap.ApplicationUnbindRequest:
+			return
+		case ldap.ApplicationSearchRequest:
+			responses = s.search(op, controls, cookies)
+		case ldap.ApplicationModifyRequest:
+			responses = append(responses, s.modify(op, controls))
+		case ldap.ApplicationExtendedRequest:
+			responses = append(responses, s.extended(op, controls))
+		case ldap.ApplicationAbandonRequest:
+			continue
+		default:
+			responses = append(responses, envelope(result(ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError, "unsupported operation")))
+		}
+		for _, response := range responses {
+			message := ber.NewSequence("LDAP Response")
+			message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgID, "MessageID"))
+			for _, child := range response.Children {
+				message.AppendChild(child)
+			}
+			if _, err := conn.Write(message.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// envelope returns the parts of an LDAP message other than the message ID,
+// which serve adds.
+func envelope(op *ber.Packet, controls ...*ber.Packet) *ber.Packet {
+	packet := ber.NewSequence("LDAP Response")
+	packet.AppendChild(op)
+	if len(controls) > 0 {
+		packed := ber.Encode(ber.ClassContext, ber.TypeConstructed, 0, nil, "Controls")
+		for _, control := range controls {
+			packed.AppendChild(control)
+		}
+		packet.AppendChild(packed)
+	}
+	return packet
+}
+
+// result returns an LDAPResult protocol operation.
+func result(tag ber.Tag, code uint16, message string) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Response")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "diagnosticMessage"))
+	return op
+}
+
+// passwordPolicyControl returns a password policy response control with
+// the given error.
+func passwordPolicyControl(policyError int8) *ber.Packet {
+	value := ber.NewSequence("PasswordPolicyResponseValue")
+	value.AppendChild(ber.NewInteger(ber.ClassContext, ber.TypePrimitive, 1, int64(policyError), "error"))
+	control := ber.NewSequence("Control")
+	control.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, ldap.ControlTypeBeheraPasswordPolicy, "Control Type"))
+	control.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(value.Bytes()), "Control Value"))
+	return control
+}
+
+func controlTypes(controls []*ber.Packet) []string {
+	var types []string
+	for _, control := range controls {
+		types = append(types, control.Children[0].Value.(string))
+	}
+	return types
+}
+
+func (s *testServer) bind(op *ber.Packet) *ber.Packet {
+	dn := op.Children[1].Value.(string)
+	password := string(op.Children[2].Data.Bytes())
+	if dn == "" {
+		return envelope(result(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess, ""))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.entry(dn)
+	if entry == nil || !slices.Contains(entry.GetAttributeValues("userPassword"), password) {
+		return envelope(result(ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials, "invalid credentials"))
+	}
+	return envelope(result(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess, ""))
+}
+
+func (s *testServer) search(op *ber.Packet, controls []*ber.Packet, cookies map[string]bool) []*ber.Packet {
+	base := op.Children[0].Value.(string)
+	scope := int(op.Children[1].Value.(int64))
+	filter := op.Children[6]
+	var attributes []string
+	for _, attribute := range op.Children[7].Children {
+		attributes = append(attributes, attribute.Value.(string))
+	}
+
+	var paging *ldap.ControlPaging
+	for _, control := range controls {
+		if control.Children[0].Value.(string) == ldap.ControlTypePaging {
+			decoded, err := ldap.DecodeControl(control)
+			if err != nil {
+				return []*ber.Packet{envelope(result(ldap.ApplicationSearchResultDone, ldap.LDAPResultProtocolError, err.Error()))}
+			}
+			paging = decoded.(*ldap.ControlPaging)
+		}
+	}
+
+	s.mu.Lock()
+	var matches []*ldap.Entry
+	for _, entry := range s.entries {
+		if inScope(entry.DN, base, scope) && matchFilter(entry, filter) {
+			matches = append(matches, entry)
+		}
+	}
+	s.mu.Unlock()
+	if len(matches) == 0 && scope == ldap.ScopeBaseObject {
+		return []*ber.Packet{envelope(result(ldap.ApplicationSearchResultDone, ldap.LDAPResultNoSuchObject, "no such object"))}
+	}
+
+	var responseControls []*ber.Packet
+	if paging != nil {
+		offset := 0
+		if len(paging.Cookie) > 0 {
+			if !cookies[string(paging.Cookie)] {
+				return []*ber.Packet{envelope(result(ldap.ApplicationSearchResultDone, ldap.LDAPResultUnwillingToPerform, "unknown paging cookie"))}
+			}
+			offset, _ = strconv.Atoi(strings.Split(string(paging.Cookie), "/")[0])
+		}
+		end := min(offset+int(paging.PagingSize), len(matches))
+		if paging.PagingSize == 0 {
+			end = len(matches)
+		}
+		next := ldap.NewControlPaging(0)
+		if end < len(matches) {
+			cookie := fmt.Sprintf("%d/%d", end, len(cookies))
+			cookies[cookie] = true
+			next.SetCookie([]byte(cookie))
+		}
+		matches = matches[offset:end]
+		responseControls = append(responseControls, next.Encode())
+	}
+
+	var responses []*ber.Packet
+	for _, entry := range matches {
+		responses = append(responses, envelope(encodeEntry(entry, attributes)))
+	}
+	return append(responses, envelope(result(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess, ""), responseControls...))
+}
+
+func encodeEntry(entry *ldap.Entry, attributes []string) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "objectName"))
+	attrs := ber.NewSequence("attributes")
+	all := len(attributes) == 0 || slices.Contains(attributes, "*")
+	for _, attribute := range entry.Attributes {
+		if !all && !slices.ContainsFunc(attributes, func(name string) bool { return strings.EqualFold(name, attribute.Name) }) {
+			continue
+		}
+		attr := ber.NewSequence("attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attribute.Name, "type"))
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, value := range attribute.ByteValues {
+			values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, string(value), "value"))
+		}
+		attr.AppendChild(values)
+		attrs.AppendChild(attr)
+	}
+	op.AppendChild(attrs)
+	return op
+}
+
+// inScope returns true if dn is within the search scope of base.
+func inScope(dn string, base string, scope int) bool {
+	dn, base = normalizeDN(dn), normalizeDN(base)
+	switch scope {
+	case ldap.ScopeBaseObject:
+		return dn == base
+	case ldap.ScopeSingleLevel:
+		_, parent, _ := strings.Cut(dn, ",")
+		return dn != "" && parent == base
+	default:
+		return dn != "" && (base == "" || dn == base || strings.HasSuffix(dn, ","+base))
+	}
+}
+
+// matchFilter evaluates a search filter on entry. Values are compared
+// ignoring case, which suits the attributes used in tests.
+func matchFilter(entry *ldap.Entry, filter *ber.Packet) bool {
+	values := func(name string) []string {
+		if strings.EqualFold(name, "distinguishedName") && entry.GetEqualFoldAttributeValue(name) == "" {
+			return []string{entry.DN}
+		}
+		return entry.GetEqualFoldAttributeValues(name)
+	}
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matchFilter(entry, child) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matchFilter(entry, child) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !matchFilter(entry, filter.Children[0])
+	case ldap.FilterEqualityMatch, ldap.FilterApproxMatch:
+		want := string(filter.Children[1].Data.Bytes())
+		return slices.ContainsFunc(values(filter.Children[0].Value.(string)), func(v string) bool {
+			return equalValues(v, want)
+		})
+	case ldap.FilterGreaterOrEqual, ldap.FilterLessOrEqual:
+		bound := string(filter.Children[1].Data.Bytes())
+		return slices.ContainsFunc(values(filter.Children[0].Value.(string)), func(v string) bool {
+			if filter.Tag == ldap.FilterGreaterOrEqual {
+				return v >= bound
+			}
+			return v <= bound
+		})
+	case ldap.FilterPresent:
+		return len(values(string(filter.Data.Bytes()))) > 0
+	case ldap.FilterSubstrings:
+		return slices.ContainsFunc(values(filter.Children[0].Value.(string)), func(v string) bool {
+			v = strings.ToLower(v)
+			for _, part := range filter.Children[1].Children {
+				s := strings.ToLower(string(part.Data.Bytes()))
+				switch part.Tag {
+				case ldap.FilterSubstringsInitial:
+					if !strings.HasPrefix(v, s) {
+						return false
+					}
+					v = v[len(s):]
+				case ldap.FilterSubstringsAny:
+					i := strings.Index(v, s)
+					if i < 0 {
+						return false
+					}
+					v = v[i+len(s):]
+				case ldap.FilterSubstringsFinal:
+					if !strings.HasSuffix(v, s) {
+						return false
+					}
+				}
+			}
+			return true
+		})
+	case ldap.FilterExtensibleMatch:
+		var rule, name, want string
+		for _, child := range filter.Children {
+			switch child.Tag {
+			case ldap.MatchingRuleAssertionMatchingRule:
+				rule = string(child.Data.Bytes())
+			case ldap.MatchingRuleAssertionType:
+				name = string(child.Data.Bytes())
+			case ldap.MatchingRuleAssertionMatchValue:
+				want = string(child.Data.Bytes())
+			}
+		}
+		return slices.ContainsFunc(values(name), func(v string) bool {
+			switch rule {
+			case "1.2.840.113556.1.4.803", "1.2.840.113556.1.4.804": // bitwise and, or
+				have, err1 := strconv.ParseInt(v, 10, 64)
+				bits, err2 := strconv.ParseInt(want, 10, 64)
+				if err1 != nil || err2 != nil {
+					return false
+				}
+				if rule == "1.2.840.113556.1.4.803" {
+					return have&bits == bits
+				}
+				return have&bits != 0
+			default:
+				return equalValues(v, want)
+			}
+		})
+	default:
+		return false
+	}
+}
+
+// equalValues compares text values ignoring case, and binary values such as
+// objectGUID exactly.
+func equalValues(a, b string) bool {
+	if !utf8.ValidString(a) || !utf8.ValidString(b) {
+		return a == b
+	}
+	return strings.EqualFold(a, b)
+}
+
+func (s *testServer) modify(op *ber.Packet, controls []*ber.Packet) *ber.Packet {
+	dn := op.Children[0].Value.(string)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry := s.entry(dn)
+	if entry == nil {
+		return envelope(result(ldap.ApplicationModifyResponse, ldap.LDAPResultNoSuchObject, "no such object"))
+	}
+	for _, change := range op.Children[1].Children {
+		operation := change.Children[0].Value.(int64)
+		name := change.Children[1].Children[0].Value.(string)
+		var values []string
+		for _, value := range change.Children[1].Children[1].Children {
+			values = append(values, string(value.Data.Bytes()))
+		}
+		if slices.ContainsFunc(s.RejectAttributes, func(rejected string) bool { return strings.EqualFold(rejected, name) }) {
+			return envelope(result(ldap.ApplicationModifyResponse, ldap.LDAPResultObjectClassViolation, "attribute "+name+" not allowed"))
+		}
+		if name == "userPassword" && s.PasswordMinLength > 0 && slices.ContainsFunc(values, func(v string) bool {
+			return len(v) < s.PasswordMinLength
+		}) {
+			return envelope(result(ldap.ApplicationModifyResponse, ldap.LDAPResultConstraintViolation, "password too short"),
+				passwordPolicyControlIfRequested(controls)...)
+		}
+		setAttribute(entry, name, func(old []string) []string {
+			switch operation {
+			case ldap.AddAttribute:
+				return append(old, values...)
+			case ldap.DeleteAttribute:
+				if len(values) == 0 {
+					return nil
+				}
+				return slices.DeleteFunc(old, func(v string) bool { return slices.Contains(values, v) })
+			default:
+				return values
+			}
+		})
+	}
+	return envelope(result(ldap.ApplicationModifyResponse, ldap.LDAPResultSuccess, ""))
+}
+
+func passwordPolicyControlIfRequested(controls []*ber.Packet) []*ber.Packet {
+	if slices.Contains(controlTypes(controls), ldap.ControlTypeBeheraPasswordPolicy) {
+		return []*ber.Packet{passwordPolicyControl(ldap.BeheraPasswordTooShort)}
+	}
+	return nil
+}
+
+// setAttribute replaces the values of an attribute with update(old values).
+func setAttribute(entry *ldap.Entry, name string, update func([]string) []string) {
+	for i, attribute := range entry.Attributes {
+		if strings.EqualFold(attribute.Name, name) {
+			values := update(slices.Clone(attribute.Values))
+			if len(values) == 0 {
+				entry.Attributes = slices.Delete(entry.Attributes, i, i+1)
+				return
+			}
+			entry.Attributes[i] = ldap.NewEntryAttribute(attribute.Name, values)
+			return
+		}
+	}
+	if values := update(nil); len(values) > 0 {
+		entry.Attributes = append(entry.Attributes, ldap.NewEntryAttribute(name, values))
+	}
+}
+
+func (s *testServer) extended(op *ber.Packet, controls []*ber.Packet) *ber.Packet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ExtendedControls = append(s.ExtendedControls, controlTypes(controls))
+
+	name := string(op.Children[0].Data.Bytes())
+	if name != passwordModifyOID {
+		return envelope(result(ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError, "unsupported extended operation"))
+	}
+	var dn, newPassword string
+	if len(op.Children) > 1 {
+		value := ber.DecodePacket(op.Children[1].Data.Bytes())
+		for _, child := range value.Children {
+			switch child.Tag {
+			case 0:
+				dn = string(child.Data.Bytes())
+			case 2:
+				newPassword = string(child.Data.Bytes())
+			}
+		}
+	}
+	entry := s.entry(dn)
+	if entry == nil {
+		return envelope(result(ldap.ApplicationExtendedResponse, ldap.LDAPResultNoSuchObject, "no such object"))
+	}
+	if s.PasswordMinLength > 0 && len(newPassword) < s.PasswordMinLength {
+		return envelope(result(ldap.ApplicationExtendedResponse, ldap.LDAPResultConstraintViolation, "password too short"),
+			passwordPolicyControlIfRequested(controls)...)
+	}
+	setAttribute(entry, "userPassword", func([]string) []string { return []string{newPassword} })
+	return envelope(result(ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess, ""))
+}
@@ -0,0 +1,136 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirldap
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// tlsConfig returns the TLS configuration for connecting to the server at
+// serverName, for both ldaps:// URLs and StartTLS.
+func (p *Provider) tlsConfig(serverName string) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if p.Config.CAFile != "" {
+		caPEM, err := os.ReadFile(p.Config.CAFile) // #nosec G304
+		if err != nil {
+			return nil, fmt.Errorf("cannot read LDAP CA file: %w", err)
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("LDAP CA file %s does not contain any PEM certificates", p.Config.CAFile)
+		}
+	}
+
+	if p.Config.ClientCertFile != "" || p.Config.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(p.Config.ClientCertFile, p.Config.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load LDAP client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if p.Config.InsecureSkipVerify {
+		config.InsecureSkipVerify = true // #nosec G402 -- explicitly requested by the operator
+	}
+	return config, nil
+}
+
+// validateTransport returns an error if the connection to the server would
+// not be encrypted, unless the operator allowed it, and warns about
+// settings that weaken TLS.
+func (p *Provider) validateTransport() error {
+	u, err := url.Parse(p.Config.LDAPUrl)
+	if err != nil {
+		return fmt.Errorf("invalid LDAP URL: %w", err)
+	}
+	if u.Scheme == "ldap" && !p.Config.StartTLS {
+		if !p.Config.AllowInsecureBind {
+			return fmt.Errorf("refusing to bind to %s without TLS; use an ldaps:// URL or enable StartTLS, "+
+				"or allow insecure binds to send credentials and passwords in cleartext", p.Config.LDAPUrl)
+		}
+		log.Printf("WARNING: connecting to the LDAP server without TLS. " +
+			"Credentials and passwords will be sent in cleartext.")
+	}
+	if p.Config.InsecureSkipVerify {
+		log.Printf("WARNING: LDAP server certificate verification is disabled. " +
+			"The connection is encrypted, but is not protected against impersonation of the server.")
+	}
+	return nil
+}
+
+// dial connects and binds to the LDAP server.
+//
+// For ldaps:// URLs the connection uses TLS from the start. For ldap:// URLs,
+// StartTLS upgrades the connection before binding if it is enabled. Without
+// TLS, it refuses to bind unless AllowInsecureBind is set; ldapi:// sockets
+// are local and need no TLS. If a client certificate is configured and there
+// is no bind DN, the client authenticates with SASL EXTERNAL using its
+// certificate.
+func (p *Provider) dial() (*ldap.Conn, error) {
+	u, err := url.Parse(p.Config.LDAPUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LDAP URL: %w", err)
+	}
+	tlsConfig, err := p.tlsConfig(u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := ldap.DialURL(p.Config.LDAPUrl, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+
+	if p.Config.StartTLS {
+		if u.Scheme == "ldaps" {
+			_ = conn.Close()
+			return nil, fmt.Errorf("StartTLS cannot be used with an ldaps:// URL")
+		}
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("StartTLS failed: %w", err)
+		}
+	}
+
+	if _, isTLS := conn.TLSConnectionState(); !isTLS && u.Scheme != "ldapi" && !p.Config.AllowInsecureBind {
+		_ = conn.Close()
+		return nil, fmt.Errorf("refusing to bind to %s without TLS", p.Config.LDAPUrl)
+	}
+
+	if p.Config.BindDN == "" && len(tlsConfig.Certificates) > 0 {
+		err = conn.ExternalBind()
+	} else {
+		err = conn.Bind(p.Config.BindDN, p.Config.BindPassword)
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) && p.RefreshCredentials.Refresh() {
+			err = conn.Bind(p.Config.BindDN, p.Config.BindPassword)
+		}
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirldap
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/nametaginc/cli/internal/config"
+)
+
+func TestValidateTransport(t *testing.T) {
+	for _, test := range []struct {
+		config  config.LDAPConfig
+		wantErr bool
+	}{
+		{config: config.LDAPConfig{LDAPUrl: "ldap://ldap.example.com"}, wantErr: true},
+		{config: config.LDAPConfig{LDAPUrl: "ldap://ldap.example.com", AllowInsecureBind: true}},
+		{config: config.LDAPConfig{LDAPUrl: "ldap://ldap.example.com", StartTLS: true}},
+		{config: config.LDAPConfig{LDAPUrl: "ldaps://ldap.example.com"}},
+		{config: config.LDAPConfig{LDAPUrl: "ldaps://ldap.example.com", InsecureSkipVerify: true}},
+		{config: config.LDAPConfig{LDAPUrl: "ldapi:///var/run/slapd/ldapi"}},
+	} {
+		p := &Provider{Config: &test.config}
+		err := p.validateTransport()
+		if got := err != nil; got != test.wantErr {
+			t.Errorf("%+v: got error %v, want error %v", test.config, err, test.wantErr)
+		}
+	}
+}
+
+func TestDialRefusesCleartextBind(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+	binds := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		buf := make([]byte, 1024)
+		n, _ := conn.Read(buf)
+		binds <- buf[:n]
+	}()
+
+	p := &Provider{Config: &config.LDAPConfig{
+		LDAPUrl:      "ldap://" + listener.Addr().String(),
+		BindDN:       "cn=admin,dc=example,dc=com",
+		BindPassword: "secret",
+	}}
+	if _, err := p.dial(); err == nil || !strings.Contains(err.Error(), "without TLS") {
+		t.Errorf("got %v, want an error refusing to bind without TLS", err)
+	}
+	if sent := <-binds; strings.Contains(string(sent), "secret") {
+		t.Errorf("the bind password was sent in cleartext")
+	}
+}
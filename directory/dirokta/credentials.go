@@ -0,0 +1,86 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirokta
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// credentialRefreshInterval is the least time between two refreshes of the
+// credentials. Refreshing may run a command for an exec: secret reference,
+// so it must not happen for every rejected request.
+const credentialRefreshInterval = time.Minute
+
+// credentialRefresher is an http.RoundTripper that reads the provider's
+// credentials again when Okta rejects them. A request made with an API token
+// is retried with the new token. Clients authenticated with a client ID hold
+// an access token obtained with the old credentials, so the next operation
+// builds a new client.
+type credentialRefresher struct {
+	p    *Provider
+	next http.RoundTripper
+}
+
+func (c *credentialRefresher) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := c.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	token, changed := c.p.refreshCredentials()
+	if !changed || token == "" || !strings.HasPrefix(req.Header.Get("Authorization"), "SSWS ") {
+		return resp, nil
+	}
+
+	retryReq := req.Clone(req.Context())
+	if req.Body != nil {
+		// the body of the original request has been consumed
+		if req.GetBody == nil {
+			return resp, nil
+		}
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return resp, nil
+		}
+		retryReq.Body = body
+	}
+	retryReq.Header.Set("Authorization", "SSWS "+token)
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return c.next.RoundTrip(retryReq)
+}
+
+// refreshCredentials reads the credentials again, unless they were read
+// less than credentialRefreshInterval ago, and returns the API token and
+// true if they changed. The client is not replaced here, since requests of
+// the current client may be in flight; the next call to client builds a new
+// one.
+func (p *Provider) refreshCredentials() (string, bool) {
+	p.credentialsMu.Lock()
+	defer p.credentialsMu.Unlock()
+
+	if !p.credentialsRefreshed.IsZero() && now().Sub(p.credentialsRefreshed) < credentialRefreshInterval {
+		return "", false
+	}
+	p.credentialsRefreshed = now()
+	if !p.RefreshCredentials.Refresh() {
+		return "", false
+	}
+	p.credentialsChanged = true
+	return p.Token, true
+}
@@ -0,0 +1,166 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirokta
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// acceptToken returns a transport that accepts requests with token only.
+func acceptToken(token *atomic.Value) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		status := http.StatusUnauthorized
+		if req.Header.Get("Authorization") == "SSWS "+token.Load().(string) {
+			status = http.StatusOK
+		}
+		return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+	})
+}
+
+func newRequest(t *testing.T, token string) *http.Request {
+	req, err := http.NewRequest(http.MethodGet, "https://example.okta.com/api/v1/users", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "SSWS "+token)
+	return req
+}
+
+func TestCredentialRefresherRetriesWithNewToken(t *testing.T) {
+	var valid atomic.Value
+	valid.Store("new")
+	var refreshes int
+	p := &Provider{Token: "old"}
+	p.RefreshCredentials = func() (bool, error) {
+		refreshes++
+		p.Token = "new"
+		return true, nil
+	}
+	c := &credentialRefresher{p: p, next: acceptToken(&valid)}
+
+	resp, err := c.RoundTrip(newRequest(t, "old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want the retry with the new token to succeed", resp.StatusCode)
+	}
+	if refreshes != 1 || !p.credentialsChanged {
+		t.Errorf("got %d refreshes and changed %t, want 1 and true", refreshes, p.credentialsChanged)
+	}
+}
+
+func TestCredentialRefresherLimitsRefreshes(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	defer func() { now = time.Now }()
+	now = func() time.Time { return start }
+
+	var valid atomic.Value
+	valid.Store("rotated")
+	var refreshes atomic.Int32
+	p := &Provider{Token: "old"}
+	p.RefreshCredentials = func() (bool, error) {
+		refreshes.Add(1)
+		return false, nil // the secret has not been rotated yet
+	}
+	c := &credentialRefresher{p: p, next: acceptToken(&valid)}
+
+	// Rejected requests at the same time refresh once.
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Go(func() {
+			resp, err := c.RoundTrip(newRequest(t, "old"))
+			if err != nil || resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("got %v, %v, want the rejection", resp, err)
+			}
+		})
+	}
+	wg.Wait()
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("got %d refreshes, want 1", got)
+	}
+
+	now = func() time.Time { return start.Add(credentialRefreshInterval - time.Second) }
+	_, _ = c.RoundTrip(newRequest(t, "old"))
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("before the interval: got %d refreshes, want 1", got)
+	}
+
+	now = func() time.Time { return start.Add(credentialRefreshInterval) }
+	_, _ = c.RoundTrip(newRequest(t, "old"))
+	if got := refreshes.Load(); got != 2 {
+		t.Errorf("after the interval: got %d refreshes, want 2", got)
+	}
+}
+
+func TestClientRebuiltAfterRefresh(t *testing.T) {
+	p := &Provider{
+		URL:          "https://example.okta.com",
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RefreshCredentials: func() (bool, error) {
+			return true, nil
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, first, err := p.client(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goroutines := runtime.NumGoroutine()
+
+	const rebuilds = 20
+	for range rebuilds {
+		p.credentialsRefreshed = time.Time{}
+		if _, changed := p.refreshCredentials(); !changed {
+			t.Fatal("credentials did not change")
+		}
+		_, client, err := p.client(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client == first {
+			t.Fatal("got the client built with the old credentials")
+		}
+	}
+
+	// The client assertion goroutine of each replaced client stops. The
+	// cache janitors that okta.NewClient starts stop when the replaced
+	// clients are collected.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > goroutines+rebuilds/2 && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := runtime.NumGoroutine(); got > goroutines+rebuilds/2 {
+		t.Errorf("%d goroutines after rebuilding the client %d times, started with %d", got, rebuilds, goroutines)
+	}
+}
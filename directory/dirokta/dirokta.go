@@ -20,6 +20,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/rehttp"
@@ -28,6 +30,7 @@ import (
 	"github.com/samber/lo"
 
 	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/directory"
 )
 
 // Provider represents the configuration details required to connect to Okta.
@@ -37,32 +40,124 @@ type Provider struct {
 	ClientID     string
 	ClientSecret string
 
+	// PrivateKey is a PEM encoded or JWK private key used to sign client
+	// assertions (private_key_jwt). When set, it is used instead of
+	// ClientSecret. KeyID is the ID Okta assigned to the matching public key.
+	PrivateKey string
+	KeyID      string
+
+	// Scopes are the OAuth scopes requested when authenticating with a
+	// client ID. Defaults to the scopes needed for every operation.
+	Scopes []string
+
+	// IDAttributes are the profile attributes that identify a user, e.g.
+	// "login" or "employeeNumber". Defaults to login, email and secondEmail.
+	IDAttributes []string
+
+	// NameAttribute is the profile attribute that holds the user's name. If
+	// it is not set, the name is built from NameTemplate, which defaults to
+	// "{firstName} {lastName}".
+	NameAttribute string
+	NameTemplate  string
+
+	// BirthDateAttribute is the profile attribute that holds the user's
+	// birth date. Defaults to "birthdate".
+	BirthDateAttribute string
+
+	// Groups, Statuses and Search limit the users that the provider exposes
+	// to members of the groups with the given IDs, users with one of the
+	// given statuses (e.g. ACTIVE), and users matching an Okta search
+	// expression respectively. Groups and Search cannot be combined.
+	Groups   []string
+	Statuses []string
+	Search   string
+
+	// IncludeGroups causes list_accounts and get_account to return each
+	// account's groups from an index of group membership, which is loaded
+	// once and then kept up to date from the system log (this requires the
+	// okta.logs.read scope). The index covers every group in the org, or only
+	// the groups in IncludeGroupIDs if it is set, typically the groups that
+	// recovery policies refer to.
+	IncludeGroups   bool
+	IncludeGroupIDs []string
+
+	// RateLimitShare is the fraction of each of the org's Okta rate limits
+	// that the agent may use. Requests are spread over each rate limit
+	// window so that the agent never starves the admin console or other
+	// integrations. Defaults to 0.5.
+	RateLimitShare float64
+
+	// MFABypassCodeTTL is how long a temporary access code issued by
+	// get_mfa_bypass_code remains valid. Defaults to 24 hours.
+	MFABypassCodeTTL time.Duration
+
+	// RefreshCredentials is called when Okta rejects the credentials, and
+	// may update Token and ClientSecret.
+	RefreshCredentials directory.RefreshCredentialsFunc
+
 	Client *okta.Client
+
+	// credentialsMu guards the credentials, which are refreshed from the
+	// HTTP transport, and the client built from them. stopClient stops the
+	// goroutine that renews the client assertion of the current client.
+	credentialsMu        sync.Mutex
+	credentialsRefreshed time.Time
+	credentialsChanged   bool
+	stopClient           context.CancelFunc
+
+	membership   *groupMembership
+	ids          *identifierIndex
+	scopeMembers map[string]map[string]bool // group ID -> user IDs
 }
 
 // Configure returns static information about the integration
 func (p *Provider) Configure(ctx context.Context, req diragentapi.DirAgentConfigureRequest) (*diragentapi.DirAgentConfigureResponse, error) {
+	for _, validate := range []func() error{p.validateProfileMapping, p.validateScope} {
+		if err := validate(); err != nil {
+			return nil, directory.CodedError{
+				Code:    diragentapi.ConfigurationError,
+				Message: err.Error(),
+			}
+		}
+	}
 	return &diragentapi.DirAgentConfigureResponse{
 		Traits: diragentapi.DirAgentTraits{
-			Name:                  "Okta",
-			CanGetPasswordLink:    lo.ToPtr(true),
-			CanRemoveAllMFA:       lo.ToPtr(true),
-			CanUnlock:             lo.ToPtr(true),
-			CanUpdateAccountsList: lo.ToPtr(true),
+			Name:                    "Okta",
+			CanGetPasswordLink:      lo.ToPtr(true),
+			CanGetTemporaryPassword: lo.ToPtr(true),
+			CanRemoveAllMFA:         lo.ToPtr(true),
+			CanGetMFABypassCode:     lo.ToPtr(p.canManageUsers()),
+			CanGetMFALink:           lo.ToPtr(true),
+			CanUnlock:               lo.ToPtr(true),
+			CanUpdateAccountsList:   lo.ToPtr(true),
 		},
 		ImmutableID: fmt.Sprintf("urn:agent:%s", p.URL),
 	}, nil
 }
 
 func (p *Provider) client(ctx context.Context) (context.Context, *okta.Client, error) {
+	p.credentialsMu.Lock()
+	defer p.credentialsMu.Unlock()
+
+	if p.credentialsChanged {
+		p.credentialsChanged = false
+		if p.stopClient != nil {
+			p.stopClient()
+			p.stopClient = nil
+		}
+		p.Client = nil
+	}
 	if p.Client != nil {
 		return ctx, p.Client, nil
 	}
-	if p.Token != "" {
-		httpClient := &http.Client{
-			Transport: retry(http.DefaultTransport),
-		}
+	httpClient := &http.Client{
+		Transport: newRateLimiter(&credentialRefresher{
+			p:    p,
+			next: retry(http.DefaultTransport),
+		}, p.RateLimitShare),
+	}
 
+	if p.Token != "" {
 		ctx, client, err := okta.NewClient(ctx,
 			okta.WithHttpClientPtr(httpClient),
 			okta.WithOrgUrl(p.URL),
@@ -76,8 +171,11 @@ func (p *Provider) client(ctx context.Context) (context.Context, *okta.Client, e
 		return ctx, client, nil
 	}
 
-	if p.ClientID != "" && p.ClientSecret != "" {
-		makeClientAssertion := p.makeClientAssertion(p.ClientID, p.ClientSecret)
+	if p.ClientID != "" && (p.ClientSecret != "" || p.PrivateKey != "") {
+		makeClientAssertion, err := p.makeClientAssertion(p.ClientID)
+		if err != nil {
+			return nil, nil, err
+		}
 
 		clientAssertion, _, err := makeClientAssertion()
 		if err != nil {
@@ -85,20 +183,23 @@ func (p *Provider) client(ctx context.Context) (context.Context, *okta.Client, e
 		}
 
 		ctx, client, err := okta.NewClient(ctx,
+			okta.WithHttpClientPtr(httpClient),
 			okta.WithOrgUrl(p.URL),
 			okta.WithAuthorizationMode("JWT"),
 			okta.WithClientAssertion(clientAssertion),
-			okta.WithScopes(oktaScopes),
+			okta.WithScopes(p.scopes()),
 			okta.WithRequestTimeout(120),
 			okta.WithRateLimitMaxRetries(10))
 		if err != nil {
 			return nil, nil, err
 		}
 
+		assertionCtx, stop := context.WithCancel(ctx)
+		p.stopClient = stop
 		go func() {
 			for {
 				select {
-				case <-ctx.Done():
+				case <-assertionCtx.Done():
 					return
 				case <-time.After(oktaClientAssertionTTL / 2):
 					clientAssertion, _, err := makeClientAssertion()
@@ -120,28 +221,69 @@ func (p *Provider) client(ctx context.Context) (context.Context, *okta.Client, e
 	return nil, nil, fmt.Errorf("okta directory credentials not configured")
 }
 
+// canManageUsers returns true if the configured credentials can modify users,
+// which is required to enroll factors on their behalf. API tokens carry the
+// permissions of the admin that created them, so we assume they can.
+func (p *Provider) canManageUsers() bool {
+	if p.Token != "" {
+		return true
+	}
+	return lo.Contains(p.scopes(), "okta.users.manage")
+}
+
+func (p *Provider) scopes() []string {
+	if len(p.Scopes) > 0 {
+		return p.Scopes
+	}
+	if p.IncludeGroups {
+		return append(slices.Clone(oktaScopes), "okta.logs.read")
+	}
+	return oktaScopes
+}
+
 const oktaClientAssertionTTL = time.Hour
 
 // now is a workaround since dirokta cannot import pkg/thunks to access thunks.TimeNow()
 var now = time.Now
 
-func (p *Provider) makeClientAssertion(clientID string, clientSecret string) func() (string, time.Time, error) {
+// makeClientAssertion returns a function that creates a signed client
+// assertion. It signs with the private key (RS256 or ES256) if one is
+// configured, and with the client secret (HS256) otherwise.
+func (p *Provider) makeClientAssertion(clientID string) (func() (string, time.Time, error), error) {
+	var signingMethod jwt.SigningMethod = jwt.SigningMethodHS256
+	var signingKey any = []byte(p.ClientSecret)
+	keyID := p.KeyID
+	if p.PrivateKey != "" {
+		privateKey, method, jwkKeyID, err := parsePrivateKey(p.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		signingMethod, signingKey = method, privateKey
+		if keyID == "" {
+			keyID = jwkKeyID
+		}
+	}
+
 	return func() (string, time.Time, error) {
 		expires := now().Add(oktaClientAssertionTTL)
 		claims := jwt.RegisteredClaims{
 			Audience:  jwt.ClaimStrings{p.URL + "/oauth2/v1/token"},
 			ExpiresAt: jwt.NewNumericDate(expires),
+			IssuedAt:  jwt.NewNumericDate(now()),
 			Issuer:    clientID,
 			Subject:   clientID,
 		}
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-		signedToken, err := token.SignedString([]byte(clientSecret))
+		token := jwt.NewWithClaims(signingMethod, claims)
+		if keyID != "" {
+			token.Header["kid"] = keyID
+		}
+		signedToken, err := token.SignedString(signingKey)
 		if err != nil {
 			return "", time.Time{}, err
 		}
 		return signedToken, expires, nil
-	}
+	}, nil
 }
 
 func retry(transport http.RoundTripper) http.RoundTripper {
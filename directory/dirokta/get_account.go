@@ -17,6 +17,7 @@ package dirokta
 import (
 	"context"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/okta/okta-sdk-golang/v2/okta"
@@ -24,6 +25,7 @@ import (
 	"github.com/samber/lo"
 
 	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/directory"
 )
 
 // GetAccount fetches accounts given one of its external IDs.
@@ -37,20 +39,123 @@ func (p *Provider) GetAccount(ctx context.Context, req diragentapi.DirAgentGetAc
 		return nil, err
 	}
 
-	queryExprs := []string{}
+	var users []*okta.User
 	if req.Ref.ImmutableID != nil {
-		queryExprs = append(queryExprs, fmt.Sprintf("(id eq %q)", *req.Ref.ImmutableID))
+		user, err := p.getUser(ctx, client, *req.Ref.ImmutableID)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			users = append(users, user)
+		}
 	}
 	if req.Ref.ID != nil {
-		queryExprs = append(queryExprs, fmt.Sprintf("(profile.login eq %q)", *req.Ref.ID))
-		queryExprs = append(queryExprs, fmt.Sprintf("(profile.email eq %q)", *req.Ref.ID))
-		queryExprs = append(queryExprs, fmt.Sprintf("(profile.secondEmail eq %q)", *req.Ref.ID))
+		matches, err := p.findUsersByID(ctx, client, *req.Ref.ID)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, matches...)
+	}
+	users = lo.UniqBy(users, func(user *okta.User) string { return user.Id })
+
+	var accounts []diragentapi.DirAgentAccount
+	for _, user := range users {
+		groups, inScope, err := p.accountGroups(ctx, client, user.Id)
+		if err != nil {
+			return nil, err
+		}
+		if !inScope {
+			continue
+		}
+		account := p.account(user)
+		account.Groups = &groups
+		accounts = append(accounts, account)
 	}
 
+	// When the provider is limited to some users, tell the server that
+	// accounts outside that scope do not exist rather than returning nothing.
+	if len(accounts) == 0 && p.isScoped() {
+		return nil, directory.CodedError{
+			Code:    diragentapi.AccountNotFound,
+			Message: "account not found",
+		}
+	}
+	return &diragentapi.DirAgentGetAccountResponse{Accounts: accounts}, nil
+}
+
+// getUser fetches a user by its Okta ID, returning nil if the user does not
+// exist or is not in scope.
+func (p *Provider) getUser(ctx context.Context, client *okta.Client, userID string) (*okta.User, error) {
+	if p.Search != "" {
+		// A search expression can only be evaluated by Okta, so search
+		// for the user instead of fetching it directly.
+		users, _, err := client.User.ListUsers(ctx, query.NewQueryParams(
+			query.WithLimit(1),
+			query.WithSearch(fmt.Sprintf("(id eq %q) and %s", userID, p.scopeSearchExpr())),
+		))
+		if err != nil {
+			return nil, fmt.Errorf("okta: failed to list users: %w", err)
+		}
+		if len(users) == 0 {
+			return nil, nil
+		}
+		p.idIndex().Set(users[0].Id, p.externalIDs(users[0]))
+		return users[0], nil
+	}
+
+	user, resp, err := client.User.GetUser(ctx, userID)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		p.idIndex().Delete(userID)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("okta: failed to get user: %w", p.filterAPIError(err))
+	}
+	p.idIndex().Set(user.Id, p.externalIDs(user))
+
+	if len(p.Statuses) > 0 && !lo.Contains(p.Statuses, user.Status) {
+		return nil, nil
+	}
+	return user, nil
+}
+
+// findUsersByID returns the users that have id as one of their identifiers.
+// It tries the users that the identifier index points to first, and falls back
+// to searching if the index has no entry or the entries are stale.
+func (p *Provider) findUsersByID(ctx context.Context, client *okta.Client, id string) ([]*okta.User, error) {
+	if candidates := p.idIndex().Lookup(id); len(candidates) > 0 {
+		var users []*okta.User
+		for _, userID := range candidates {
+			user, err := p.getUser(ctx, client, userID)
+			if err != nil {
+				return nil, err
+			}
+			if user != nil && lo.ContainsBy(p.externalIDs(user), func(ident string) bool {
+				return strings.EqualFold(ident, id)
+			}) {
+				users = append(users, user)
+			}
+		}
+		if len(users) > 0 {
+			return users, nil
+		}
+	}
+
+	return p.searchUsersByID(ctx, client, id)
+}
+
+// searchUsersByID searches for users that have id in one of the identifier
+// attributes, and records the results in the identifier index.
+func (p *Provider) searchUsersByID(ctx context.Context, client *okta.Client, id string) ([]*okta.User, error) {
+	queryExprs := lo.Map(p.idAttributes(), func(attribute string, _ int) string {
+		return fmt.Sprintf("(profile.%s eq %q)", attribute, id)
+	})
 	queryExpr := strings.Join(queryExprs, " or ")
+	if scopeExpr := p.scopeSearchExpr(); scopeExpr != "" {
+		queryExpr = fmt.Sprintf("(%s) and %s", queryExpr, scopeExpr)
+	}
 
-	var users []*okta.User
-	usersPage, resp, err := client.User.ListUsers(ctx,
+	users, resp, err := client.User.ListUsers(ctx,
 		query.NewQueryParams(
 			query.WithLimit(250),
 			query.WithSearch(queryExpr),
@@ -58,37 +163,42 @@ func (p *Provider) GetAccount(ctx context.Context, req diragentapi.DirAgentGetAc
 	if err != nil {
 		return nil, fmt.Errorf("okta: failed to list users: %w", err)
 	}
-	users = append(users, usersPage...)
 	for resp.HasNextPage() {
 		var usersPage []*okta.User
-		resp, err = resp.Next(ctx, &users)
+		resp, err = resp.Next(ctx, &usersPage)
 		if err != nil {
 			return nil, fmt.Errorf("okta: failed to list users: %w", err)
 		}
 		users = append(users, usersPage...)
 	}
 
-	var accounts []diragentapi.DirAgentAccount
-
 	for _, user := range users {
-		groups, _, err := client.User.ListUserGroups(ctx, user.Id)
-		if err != nil {
-			return nil, fmt.Errorf("okta: failed to list groups for user: %w", err)
+		p.idIndex().Set(user.Id, p.externalIDs(user))
+	}
+	return users, nil
+}
+
+// accountGroups returns the groups of the user with userID, and whether the
+// user is in one of the groups that limit the users in scope, if any. With
+// IncludeGroups, the groups come from the membership index that
+// list_accounts keeps, rather than from an API call per user.
+func (p *Provider) accountGroups(ctx context.Context, client *okta.Client, userID string) ([]diragentapi.DirAgentGroup, bool, error) {
+	if p.IncludeGroups {
+		if err := p.freshGroupMembership(ctx, client); err != nil {
+			return nil, false, err
 		}
+		return p.membership.userGroups(userID), p.membership.inScope(p.Groups, userID), nil
+	}
 
-		userGroups := lo.Map(groups, func(item *okta.Group, _ int) diragentapi.DirAgentGroup {
-			return diragentapi.DirAgentGroup{
-				ImmutableID: item.Id,
-				Name:        lo.FromPtr(item.Profile).Name,
-				Kind:        "group",
-			}
-		})
-		accounts = append(accounts, diragentapi.DirAgentAccount{
-			ImmutableID: user.Id,
-			IDs:         p.externalIDs(user),
-			Name:        p.displayName(user),
-			Groups:      &userGroups,
-		})
+	groups, _, err := client.User.ListUserGroups(ctx, userID)
+	if err != nil {
+		return nil, false, fmt.Errorf("okta: failed to list groups for user: %w", err)
 	}
-	return &diragentapi.DirAgentGetAccountResponse{Accounts: accounts}, nil
+	return lo.Map(groups, func(item *okta.Group, _ int) diragentapi.DirAgentGroup {
+		return diragentapi.DirAgentGroup{
+			ImmutableID: item.Id,
+			Name:        groupName(item),
+			Kind:        "group",
+		}
+	}), p.inGroupScope(groups), nil
 }
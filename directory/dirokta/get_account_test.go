@@ -0,0 +1,98 @@
+// Copyright 2025 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirokta
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/samber/lo"
+
+	"github.com/nametaginc/cli/diragentapi"
+)
+
+func TestGetAccountGroupsFromIndex(t *testing.T) {
+	org := newTestOkta(t)
+	org.Handle("GET /api/v1/users/{id}", func(r *http.Request) any {
+		return okta.User{Id: r.PathValue("id"), Status: "ACTIVE", Profile: &okta.UserProfile{"login": r.PathValue("id") + "@example.com"}}
+	})
+	org.Handle("GET /api/v1/users/{id}/groups", func(r *http.Request) any {
+		t.Errorf("listed the groups of %s", r.PathValue("id"))
+		return []okta.Group{}
+	})
+	org.Handle("GET /api/v1/groups", func(r *http.Request) any {
+		return []okta.Group{
+			{Id: "g1", Profile: &okta.GroupProfile{Name: "Admins"}},
+			{Id: "g2", Profile: &okta.GroupProfile{Name: "Staff"}},
+		}
+	})
+	org.Handle("GET /api/v1/groups/{id}/users", func(r *http.Request) any {
+		return map[string][]okta.User{
+			"g1": {{Id: "u1"}},
+			"g2": {{Id: "u2"}},
+		}[r.PathValue("id")]
+	})
+	org.Handle("GET /api/v1/logs", func(r *http.Request) any {
+		return []okta.LogEvent{}
+	})
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now = func() time.Time { return start }
+	defer func() { now = time.Now }()
+
+	p := org.Provider()
+	p.IncludeGroups = true
+	p.Groups = []string{"g1"}
+	getAccount := func(userID string) []diragentapi.DirAgentAccount {
+		resp, err := p.GetAccount(context.Background(), diragentapi.DirAgentGetAccountRequest{
+			Ref: diragentapi.DirAgentAccountRef{ImmutableID: lo.ToPtr(userID)},
+		})
+		if err != nil {
+			// u2 is not in the scope group
+			if userID == "u2" && isAccountNotFound(err) {
+				return nil
+			}
+			t.Fatal(err)
+		}
+		return resp.Accounts
+	}
+
+	accounts := getAccount("u1")
+	if len(accounts) != 1 || !slices.Equal(lo.FromPtr(accounts[0].Groups), []diragentapi.DirAgentGroup{{ImmutableID: "g1", Name: "Admins", Kind: "group"}}) {
+		t.Errorf("u1: got %+v", accounts)
+	}
+	if accounts := getAccount("u2"); len(accounts) != 0 {
+		t.Errorf("u2: got %+v, want none outside the scope group", accounts)
+	}
+
+	countLogReads := func() int {
+		return len(lo.Filter(org.Requests(), func(request string, _ int) bool {
+			return strings.HasSuffix(request, "/api/v1/logs")
+		}))
+	}
+	if got := countLogReads(); got != 0 {
+		t.Errorf("got %d system log reads with fresh membership, want 0", got)
+	}
+	now = func() time.Time { return start.Add(membershipMaxAge + time.Second) }
+	getAccount("u1")
+	if got := countLogReads(); got != 1 {
+		t.Errorf("got %d system log reads with old membership, want 1", got)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirokta
+
+import (
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// identifierIndex maps account identifiers (login, email, etc.) to the IDs of
+// the users that have them. It is filled in as list_accounts pages through
+// the directory, which lets get_account fetch a user by ID instead of running
+// a search. Entries may be stale, so callers must check the user they fetch.
+type identifierIndex struct {
+	users  map[string][]string // identifier -> user IDs
+	idents map[string][]string // user ID -> identifiers
+}
+
+func newIdentifierIndex() *identifierIndex {
+	return &identifierIndex{
+		users:  map[string][]string{},
+		idents: map[string][]string{},
+	}
+}
+
+// normalizeIdentifier folds case, since Okta compares logins and email
+// addresses case-insensitively.
+func normalizeIdentifier(ident string) string {
+	return strings.ToLower(strings.TrimSpace(ident))
+}
+
+// Set records that the user with userID has exactly the given identifiers,
+// replacing whatever was recorded for it before.
+func (x *identifierIndex) Set(userID string, idents []string) {
+	x.Delete(userID)
+	idents = lo.Uniq(lo.Map(idents, func(ident string, _ int) string {
+		return normalizeIdentifier(ident)
+	}))
+	for _, ident := range idents {
+		x.users[ident] = append(x.users[ident], userID)
+	}
+	x.idents[userID] = idents
+}
+
+// Delete removes the user with userID from the index.
+func (x *identifierIndex) Delete(userID string) {
+	for _, ident := range x.idents[userID] {
+		x.users[ident] = lo.Without(x.users[ident], userID)
+		if len(x.users[ident]) == 0 {
+			delete(x.users, ident)
+		}
+	}
+	delete(x.idents, userID)
+}
+
+// Lookup returns the IDs of the users that had ident when they were last
+// indexed.
+func (x *identifierIndex) Lookup(ident string) []string {
+	return x.users[normalizeIdentifier(ident)]
+}
+
+// idIndex returns the provider's identifier index, creating it if needed.
+func (p *Provider) idIndex() *identifierIndex {
+	if p.ids == nil {
+		p.ids = newIdentifierIndex()
+	}
+	return p.ids
+}
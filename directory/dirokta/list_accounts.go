@@ -18,7 +18,6 @@ import (
 	"context"
 	"fmt"
 	"net/url"
-	"strings"
 
 	"github.com/okta/okta-sdk-golang/v2/okta"
 	"github.com/okta/okta-sdk-golang/v2/okta/query"
@@ -37,16 +36,16 @@ func (p *Provider) ListAccounts(ctx context.Context, req diragentapi.DirAgentLis
 		return nil, err
 	}
 
+	if len(p.Groups) > 0 {
+		return p.listGroupAccounts(ctx, client, req)
+	}
+
 	rv := diragentapi.DirAgentListAccountsResponse{}
 
 	paramOptions := []query.ParamOptions{
 		query.WithLimit(250),
 	}
-	if req.UpdatedAfter != nil {
-		paramOptions = append(paramOptions, query.WithFilter(
-			fmt.Sprintf("lastUpdated gt \"%s\"",
-				req.UpdatedAfter.Format(oktaTimeFormat))))
-	}
+	paramOptions = append(paramOptions, p.userQuery(req.UpdatedAfter)...)
 	if req.Cursor != nil {
 		paramOptions = append(paramOptions, query.WithAfter(*req.Cursor))
 	}
@@ -55,61 +54,47 @@ func (p *Provider) ListAccounts(ctx context.Context, req diragentapi.DirAgentLis
 	if err != nil {
 		return nil, fmt.Errorf("okta: failed to list users: %w", err)
 	}
+	logRateLimit(resp.Response)
 
 	for _, user := range users {
-		account := diragentapi.DirAgentAccount{
-			ImmutableID: user.Id,
-			IDs:         p.externalIDs(user),
-			Name:        p.displayName(user),
-			UpdatedAt:   user.LastUpdated,
-		}
-		if user.Profile != nil {
-			if birthDate, ok := (*user.Profile)["birthdate"]; ok {
-				if birthDateStr, ok := birthDate.(string); ok {
-					account.BirthDate = &birthDateStr
-				}
-			}
-		}
-
+		account := p.account(user)
+		p.idIndex().Set(user.Id, account.IDs)
 		rv.Accounts = append(rv.Accounts, account)
 	}
+	if err := p.addGroups(ctx, client, req.Cursor, rv.Accounts); err != nil {
+		return nil, err
+	}
 
-	if resp.HasNextPage() {
-		nextURL, err := url.Parse(resp.NextPage)
+	nextCursor, err := nextPageCursor(resp)
+	if err != nil {
+		return nil, err
+	}
+	if nextCursor != "" {
+		rv.NextCursor = lo.ToPtr(nextCursor)
+	} else if req.UpdatedAfter != nil {
+		changed, err := p.membershipChangedAccounts(ctx, client, *req.UpdatedAfter)
 		if err != nil {
-			return nil, fmt.Errorf("expected next URL to be valid, got %q: %w", resp.NextPage, err)
-		}
-		nextCursor := nextURL.Query().Get("after")
-		if nextCursor == "" {
-			return nil, fmt.Errorf("expected next URL to have an `after` parameter, got %q", resp.NextPage)
+			return nil, err
 		}
-
-		rv.NextCursor = lo.ToPtr(nextCursor)
+		rv.Accounts = append(rv.Accounts, changed...)
 	}
 
 	return &rv, nil
 }
 
-func (p *Provider) externalIDs(user *okta.User) []string {
-	var rv []string
-	profile := user.Profile
-	if profile != nil {
-		for _, fieldName := range []string{"login", "email", "secondEmail"} {
-			value, _ := (*profile)[fieldName].(string)
-			if value != "" {
-				rv = append(rv, value)
-			}
-		}
+// nextPageCursor returns the `after` parameter of the next page of results,
+// or an empty string if this is the last page.
+func nextPageCursor(resp *okta.Response) (string, error) {
+	if !resp.HasNextPage() {
+		return "", nil
 	}
-	return rv
-}
-
-func (p *Provider) displayName(user *okta.User) string {
-	profile := user.Profile
-	if profile == nil {
-		return ""
+	nextURL, err := url.Parse(resp.NextPage)
+	if err != nil {
+		return "", fmt.Errorf("expected next URL to be valid, got %q: %w", resp.NextPage, err)
+	}
+	nextCursor := nextURL.Query().Get("after")
+	if nextCursor == "" {
+		return "", fmt.Errorf("expected next URL to have an `after` parameter, got %q", resp.NextPage)
 	}
-	firstName, _ := (*profile)["firstName"].(string)
-	lastName, _ := (*profile)["lastName"].(string)
-	return strings.TrimSpace(firstName + " " + lastName)
+	return nextCursor, nil
 }
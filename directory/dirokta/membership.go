@@ -0,0 +1,355 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirokta
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/okta/okta-sdk-golang/v2/okta/query"
+	"github.com/samber/lo"
+
+	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/directory"
+)
+
+// Event types in the Okta system log for changes to group membership.
+const (
+	eventGroupMembershipAdd    = "group.user_membership.add"
+	eventGroupMembershipRemove = "group.user_membership.remove"
+)
+
+// membershipMaxAge is how old the group membership may be when get_account
+// uses it. Older membership is brought up to date from the system log first.
+const membershipMaxAge = time.Minute
+
+// systemLogDelay is how far back from the last read the system log is read
+// again, because events can take a while to appear in it.
+const systemLogDelay = 5 * time.Minute
+
+// groupMembership is the membership of the included groups, and of the
+// groups that limit the users in scope. It is loaded once, and then kept up
+// to date from the system log.
+type groupMembership struct {
+	// all is true if every group in the org is included, in which case
+	// groups created after the membership was loaded are added as their
+	// members change.
+	all bool
+
+	included []string                             // IDs of the groups returned with accounts, in order
+	groups   map[string]diragentapi.DirAgentGroup // group ID -> group
+	members  map[string]map[string]bool           // user ID -> group IDs
+	changed  map[string]time.Time                 // user ID -> time of the last change to its groups
+	polled   time.Time                            // changes before this time have been applied
+}
+
+// addGroups fills in Groups for each account from the group membership.
+//
+// Rather than listing the groups of every user, which costs one API call per
+// user, we list the members of each of the included groups once, and then
+// apply the membership changes recorded in the system log at the start of
+// each sync, which is when cursor is nil.
+//
+// The agent protocol does not tell the agent which groups recovery policies
+// refer to, so every group in the org is included unless IncludeGroupIDs
+// narrows them down.
+func (p *Provider) addGroups(ctx context.Context, client *okta.Client, cursor *string, accounts []diragentapi.DirAgentAccount) error {
+	if !p.IncludeGroups {
+		return nil
+	}
+	switch {
+	case p.membership == nil:
+		if err := p.loadGroupMembership(ctx, client); err != nil {
+			return err
+		}
+	case cursor == nil:
+		if err := p.refreshGroupMembership(ctx, client); err != nil {
+			return err
+		}
+	}
+
+	for i := range accounts {
+		groups := p.membership.userGroups(accounts[i].ImmutableID)
+		accounts[i].Groups = &groups
+	}
+	return nil
+}
+
+// freshGroupMembership loads the group membership, or brings it up to date
+// if it was last read more than membershipMaxAge ago.
+func (p *Provider) freshGroupMembership(ctx context.Context, client *okta.Client) error {
+	switch {
+	case p.membership == nil:
+		return p.loadGroupMembership(ctx, client)
+	case now().Sub(p.membership.polled) > membershipMaxAge:
+		return p.refreshGroupMembership(ctx, client)
+	}
+	return nil
+}
+
+// inScope returns true if the user with userID is a member of one of the
+// groups with groupIDs, or if there are none.
+func (m *groupMembership) inScope(groupIDs []string, userID string) bool {
+	if len(groupIDs) == 0 {
+		return true
+	}
+	return lo.ContainsBy(groupIDs, func(groupID string) bool {
+		return m.members[userID][groupID]
+	})
+}
+
+// userGroups returns the included groups of the user with userID.
+func (m *groupMembership) userGroups(userID string) []diragentapi.DirAgentGroup {
+	groups := []diragentapi.DirAgentGroup{}
+	for _, groupID := range m.included {
+		if m.members[userID][groupID] {
+			groups = append(groups, m.groups[groupID])
+		}
+	}
+	return groups
+}
+
+// set records whether the user with userID is a member of the group with
+// groupID, and returns true if that is a change.
+func (m *groupMembership) set(userID string, groupID string, member bool) bool {
+	if m.members[userID][groupID] == member {
+		return false
+	}
+	if member {
+		if m.members[userID] == nil {
+			m.members[userID] = map[string]bool{}
+		}
+		m.members[userID][groupID] = true
+	} else {
+		delete(m.members[userID], groupID)
+	}
+	return true
+}
+
+// loadGroupMembership lists the members of each of the included groups and
+// of the groups that limit the users in scope. If the membership was loaded
+// before, users whose groups differ are recorded as changed.
+func (p *Provider) loadGroupMembership(ctx context.Context, client *okta.Client) error {
+	start := now()
+	m := &groupMembership{
+		all:     len(p.IncludeGroupIDs) == 0,
+		groups:  map[string]diragentapi.DirAgentGroup{},
+		members: map[string]map[string]bool{},
+		changed: map[string]time.Time{},
+	}
+	if m.all {
+		groups, resp, err := client.Group.ListGroups(ctx, query.NewQueryParams(query.WithLimit(10000)))
+		for {
+			if err != nil {
+				return fmt.Errorf("okta: failed to list groups: %w", err)
+			}
+			for _, group := range groups {
+				m.addGroup(group.Id, groupName(group))
+			}
+			if !resp.HasNextPage() {
+				break
+			}
+			groups = nil
+			resp, err = resp.Next(ctx, &groups)
+		}
+	}
+	for _, groupID := range lo.Uniq(append(slices.Clone(p.IncludeGroupIDs), p.Groups...)) {
+		if _, ok := m.groups[groupID]; ok {
+			continue
+		}
+		group, _, err := client.Group.GetGroup(ctx, groupID)
+		if err != nil {
+			return fmt.Errorf("okta: failed to get group %s: %w", groupID, p.filterAPIError(err))
+		}
+		m.groups[groupID] = diragentapi.DirAgentGroup{
+			ImmutableID: group.Id,
+			Name:        groupName(group),
+			Kind:        "group",
+		}
+	}
+	if !m.all {
+		m.included = p.IncludeGroupIDs
+	}
+
+	for groupID := range m.groups {
+		users, resp, err := client.Group.ListGroupUsers(ctx, groupID, query.NewQueryParams(query.WithLimit(1000)))
+		for {
+			if err != nil {
+				return fmt.Errorf("okta: failed to list group members: %w", err)
+			}
+			for _, user := range users {
+				m.set(user.Id, groupID, true)
+			}
+			if !resp.HasNextPage() {
+				break
+			}
+			users = nil
+			resp, err = resp.Next(ctx, &users)
+		}
+	}
+
+	if old := p.membership; old != nil {
+		m.changed = old.changed
+		for userID := range lo.Assign(old.members, m.members) {
+			if !lo.ElementsMatch(lo.Keys(old.members[userID]), lo.Keys(m.members[userID])) {
+				m.changed[userID] = start
+			}
+		}
+	}
+	m.polled = start
+	p.membership = m
+	log.Printf("okta: loaded membership of %d groups for %d users", len(m.groups), len(m.members))
+	return nil
+}
+
+// refreshGroupMembership applies the membership changes recorded in the
+// system log since it was last read. If the system log cannot be read, for
+// example because the okta.logs.read scope was not granted, the membership
+// is loaded again instead.
+func (p *Provider) refreshGroupMembership(ctx context.Context, client *okta.Client) error {
+	m := p.membership
+	start := now()
+	events, resp, err := client.LogEvent.GetLogs(ctx, query.NewQueryParams(
+		query.WithSince(m.polled.Add(-systemLogDelay).UTC().Format(oktaTimeFormat)),
+		query.WithFilter(fmt.Sprintf("eventType eq %q or eventType eq %q", eventGroupMembershipAdd, eventGroupMembershipRemove)),
+		query.WithSortOrder("ASCENDING"),
+		query.WithLimit(1000),
+	))
+	var applied int
+	for {
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusForbidden {
+				log.Printf("okta: cannot read the system log, loading group membership again: %v", err)
+				return p.loadGroupMembership(ctx, client)
+			}
+			return fmt.Errorf("okta: failed to read the system log: %w", err)
+		}
+		for _, event := range events {
+			if m.apply(event) {
+				applied++
+			}
+		}
+		if !resp.HasNextPage() {
+			break
+		}
+		events = nil
+		resp, err = resp.Next(ctx, &events)
+	}
+	m.polled = start
+	log.Printf("okta: applied %d group membership changes", applied)
+	return nil
+}
+
+// apply applies a group membership event from the system log, and returns
+// true if it changed the membership. Events are replayed when the system
+// log is read again, which is harmless because they are applied in order.
+func (m *groupMembership) apply(event *okta.LogEvent) bool {
+	if event.Outcome != nil && event.Outcome.Result != "SUCCESS" {
+		return false
+	}
+	var userID, groupID, groupName string
+	for _, target := range event.Target {
+		switch target.Type {
+		case "User":
+			userID = target.Id
+		case "UserGroup":
+			groupID, groupName = target.Id, target.DisplayName
+		}
+	}
+	if userID == "" || groupID == "" {
+		return false
+	}
+	if _, ok := m.groups[groupID]; !ok {
+		if !m.all {
+			return false
+		}
+		m.addGroup(groupID, groupName)
+	}
+	if !m.set(userID, groupID, event.EventType == eventGroupMembershipAdd) {
+		return false
+	}
+	if event.Published != nil && event.Published.After(m.changed[userID]) {
+		m.changed[userID] = *event.Published
+	} else if event.Published == nil {
+		m.changed[userID] = now()
+	}
+	return true
+}
+
+// membershipChangedAccounts returns the accounts in scope whose groups
+// changed after updatedAfter, but that were not updated themselves, because
+// Okta does not update a user's lastUpdated when its groups change. An
+// incremental sync would otherwise miss them.
+func (p *Provider) membershipChangedAccounts(ctx context.Context, client *okta.Client, updatedAfter time.Time) ([]diragentapi.DirAgentAccount, error) {
+	if !p.IncludeGroups || p.membership == nil {
+		return nil, nil
+	}
+	var accounts []diragentapi.DirAgentAccount
+	for userID, changed := range p.membership.changed {
+		if !changed.After(updatedAfter) {
+			continue
+		}
+		user, err := p.getUser(ctx, client, userID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			continue
+		}
+		if user.LastUpdated != nil && user.LastUpdated.After(updatedAfter) {
+			continue // already listed
+		}
+		if len(p.Groups) > 0 {
+			if err := p.checkScope(ctx, userID); isAccountNotFound(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		account := p.account(user)
+		groups := p.membership.userGroups(userID)
+		account.Groups = &groups
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+// addGroup adds a group to the included groups.
+func (m *groupMembership) addGroup(groupID string, name string) {
+	m.groups[groupID] = diragentapi.DirAgentGroup{
+		ImmutableID: groupID,
+		Name:        name,
+		Kind:        "group",
+	}
+	m.included = append(m.included, groupID)
+}
+
+func isAccountNotFound(err error) bool {
+	var codedErr directory.CodedError
+	return errors.As(err, &codedErr) && codedErr.Code == diragentapi.AccountNotFound
+}
+
+func groupName(group *okta.Group) string {
+	if group.Profile == nil {
+		return ""
+	}
+	return group.Profile.Name
+}
@@ -0,0 +1,177 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirokta
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/samber/lo"
+
+	"github.com/nametaginc/cli/diragentapi"
+)
+
+func TestGroupMembershipApply(t *testing.T) {
+	m := &groupMembership{
+		included: []string{"g1", "g2"},
+		groups: map[string]diragentapi.DirAgentGroup{
+			"g1": {ImmutableID: "g1", Name: "Admins", Kind: "group"},
+			"g2": {ImmutableID: "g2", Name: "Staff", Kind: "group"},
+		},
+		members: map[string]map[string]bool{"u1": {"g1": true}},
+		changed: map[string]time.Time{},
+	}
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	event := func(eventType string, minutes int, userID, groupID string) *okta.LogEvent {
+		return &okta.LogEvent{
+			EventType: eventType,
+			Published: lo.ToPtr(t0.Add(time.Duration(minutes) * time.Minute)),
+			Outcome:   &okta.LogOutcome{Result: "SUCCESS"},
+			Target: []*okta.LogTarget{
+				{Id: userID, Type: "User"},
+				{Id: groupID, Type: "UserGroup"},
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		event   *okta.LogEvent
+		applied bool
+	}{
+		{"add", event(eventGroupMembershipAdd, 1, "u2", "g2"), true},
+		{"replayed add", event(eventGroupMembershipAdd, 1, "u2", "g2"), false},
+		{"remove", event(eventGroupMembershipRemove, 2, "u1", "g1"), true},
+		{"group not included", event(eventGroupMembershipAdd, 3, "u1", "g3"), false},
+		{"failed", &okta.LogEvent{
+			EventType: eventGroupMembershipAdd,
+			Outcome:   &okta.LogOutcome{Result: "FAILURE"},
+			Target:    []*okta.LogTarget{{Id: "u3", Type: "User"}, {Id: "g1", Type: "UserGroup"}},
+		}, false},
+	} {
+		if got := m.apply(tc.event); got != tc.applied {
+			t.Errorf("%s: got applied %v, want %v", tc.name, got, tc.applied)
+		}
+	}
+
+	if got := m.userGroups("u1"); len(got) != 0 {
+		t.Errorf("u1: got groups %v, want none", got)
+	}
+	if got := m.userGroups("u2"); len(got) != 1 || got[0].Name != "Staff" {
+		t.Errorf("u2: got groups %v, want Staff", got)
+	}
+	if got, want := m.changed["u1"], t0.Add(2*time.Minute); !got.Equal(want) {
+		t.Errorf("u1: got changed at %s, want %s", got, want)
+	}
+	if _, ok := m.changed["u3"]; ok {
+		t.Errorf("u3: recorded a change for a failed event")
+	}
+}
+
+func TestGroupMembershipApplyNewGroup(t *testing.T) {
+	m := &groupMembership{
+		all:      true,
+		included: []string{"g1"},
+		groups:   map[string]diragentapi.DirAgentGroup{"g1": {ImmutableID: "g1", Name: "Admins", Kind: "group"}},
+		members:  map[string]map[string]bool{},
+		changed:  map[string]time.Time{},
+	}
+	applied := m.apply(&okta.LogEvent{
+		EventType: eventGroupMembershipAdd,
+		Target: []*okta.LogTarget{
+			{Id: "u1", Type: "User"},
+			{Id: "g2", Type: "UserGroup", DisplayName: "Contractors"},
+		},
+	})
+	if !applied {
+		t.Fatal("the event for a group created after loading was not applied")
+	}
+	want := []diragentapi.DirAgentGroup{{ImmutableID: "g2", Name: "Contractors", Kind: "group"}}
+	if got := m.userGroups("u1"); !slices.Equal(got, want) {
+		t.Errorf("u1: got groups %v, want %v", got, want)
+	}
+}
+
+func TestLoadGroupMembership(t *testing.T) {
+	org := newTestOkta(t)
+	org.Handle("GET /api/v1/groups", func(r *http.Request) any {
+		return []okta.Group{
+			{Id: "g1", Profile: &okta.GroupProfile{Name: "Admins"}},
+			{Id: "g2", Profile: &okta.GroupProfile{Name: "Staff"}},
+		}
+	})
+	org.Handle("GET /api/v1/groups/{id}", func(r *http.Request) any {
+		return okta.Group{Id: r.PathValue("id"), Profile: &okta.GroupProfile{Name: "Group " + r.PathValue("id")}}
+	})
+	org.Handle("GET /api/v1/groups/{id}/users", func(r *http.Request) any {
+		return map[string][]okta.User{
+			"g1": {{Id: "u1"}},
+			"g2": {{Id: "u1"}, {Id: "u2"}},
+			"g3": {{Id: "u2"}},
+		}[r.PathValue("id")]
+	})
+
+	for _, test := range []struct {
+		name     string
+		include  []string
+		scope    []string
+		want     map[string][]string
+		requests int
+	}{
+		{
+			name:     "every group",
+			want:     map[string][]string{"u1": {"g1", "g2"}, "u2": {"g2"}},
+			requests: 3,
+		},
+		{
+			name:     "included groups",
+			include:  []string{"g3", "g1"},
+			want:     map[string][]string{"u1": {"g1"}, "u2": {"g3"}},
+			requests: 4,
+		},
+		{
+			// scope groups are indexed, but not returned
+			name:     "included and scope groups",
+			include:  []string{"g1"},
+			scope:    []string{"g2"},
+			want:     map[string][]string{"u1": {"g1"}, "u2": {}},
+			requests: 4,
+		},
+	} {
+		p := org.Provider()
+		p.IncludeGroups, p.IncludeGroupIDs, p.Groups = true, test.include, test.scope
+		before := len(org.Requests())
+		if err := p.loadGroupMembership(context.Background(), p.Client); err != nil {
+			t.Fatal(err)
+		}
+		if got := len(org.Requests()) - before; got != test.requests {
+			t.Errorf("%s: got %d requests, want %d", test.name, got, test.requests)
+		}
+		for userID, want := range test.want {
+			got := lo.Map(p.membership.userGroups(userID), func(group diragentapi.DirAgentGroup, _ int) string {
+				return group.ImmutableID
+			})
+			slices.Sort(got)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("%s: %s: got groups %v, want %v", test.name, userID, got, want)
+			}
+		}
+	}
+}
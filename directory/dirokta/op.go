@@ -27,9 +27,19 @@ import (
 
 // PerformOperation performs the specified recovery operation
 func (p *Provider) PerformOperation(ctx context.Context, req diragentapi.DirAgentPerformOperationRequest) (*diragentapi.DirAgentPerformOperationResponse, error) {
+	if err := p.checkScope(ctx, req.AccountImmutableID); err != nil {
+		return nil, err
+	}
+
 	switch req.Operation {
 	case diragentapi.GetPasswordLink:
 		return p.performOperationGetPasswordLink(ctx, req)
+	case diragentapi.GetTemporaryPassword:
+		return p.performOperationGetTemporaryPassword(ctx, req)
+	case diragentapi.GetMFALink:
+		return p.performOperationGetMFALink(ctx, req)
+	case diragentapi.GetMFABypassCode:
+		return p.performOperationGetMFABypassCode(ctx, req)
 	case diragentapi.RemoveAllMFA:
 		return p.performOperationRemoveAllMfa(ctx, req)
 	case diragentapi.Unlock:
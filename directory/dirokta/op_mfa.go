@@ -16,7 +16,10 @@ package dirokta
 
 import (
 	"context"
+	"fmt"
 
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/okta/okta-sdk-golang/v2/okta/query"
 	"github.com/samber/lo"
 
 	"github.com/nametaginc/cli/diragentapi"
@@ -50,3 +53,67 @@ func (p *Provider) performOperationRemoveAllMfa(ctx context.Context, req diragen
 
 	return &diragentapi.DirAgentPerformOperationResponse{}, nil
 }
+
+// performOperationGetMFALink returns a link that leads the user through
+// enrolling new MFA factors.
+//
+// A user that has not signed in yet gets an activation link, which leads
+// through setting a password and enrolling factors. Okta has no factor
+// enrollment link for a user that is already active, so their factors are
+// removed and they get a password recovery link instead: Okta prompts them
+// to enroll new factors once they set a password. Until they follow the
+// link, they cannot sign in with their old password.
+func (p *Provider) performOperationGetMFALink(ctx context.Context, req diragentapi.DirAgentPerformOperationRequest) (*diragentapi.DirAgentPerformOperationResponse, error) {
+	ctx, oktaClient, err := p.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	u, _, err := oktaClient.User.GetUser(ctx, req.AccountImmutableID)
+	if err != nil {
+		return nil, p.filterAPIError(err)
+	}
+	switch u.Status {
+	case "STAGED", "PROVISIONED", "ACTIVE", "PASSWORD_EXPIRED", "RECOVERY", "LOCKED_OUT":
+	default:
+		return nil, directory.CodedError{
+			Code:    diragentapi.UnsupportedAccountState,
+			Message: fmt.Sprintf("cannot get an MFA link for an account with status %s", u.Status),
+		}
+	}
+
+	if lo.FromPtr(req.DryRun) {
+		return &diragentapi.DirAgentPerformOperationResponse{}, nil
+	}
+
+	// None of these calls sends an email, so the link is only given to the
+	// caller.
+	qp := query.NewQueryParams(query.WithSendEmail(false))
+	var link string
+	switch u.Status {
+	case "STAGED", "PROVISIONED":
+		var activationToken *okta.UserActivationToken
+		if u.Status == "STAGED" {
+			activationToken, _, err = oktaClient.User.ActivateUser(ctx, req.AccountImmutableID, qp)
+		} else {
+			activationToken, _, err = oktaClient.User.ReactivateUser(ctx, req.AccountImmutableID, qp)
+		}
+		if err != nil {
+			return nil, p.filterAPIError(err)
+		}
+		link = activationToken.ActivationUrl
+
+	default:
+		if _, err := oktaClient.User.ResetFactors(ctx, req.AccountImmutableID); err != nil {
+			return nil, p.filterAPIError(err)
+		}
+		resetPasswordToken, _, err := oktaClient.User.ResetPassword(ctx, req.AccountImmutableID, qp)
+		if err != nil {
+			return nil, p.filterAPIError(err)
+		}
+		link = resetPasswordToken.ResetPasswordUrl
+	}
+	return &diragentapi.DirAgentPerformOperationResponse{
+		MfaResetLink: &link,
+	}, nil
+}
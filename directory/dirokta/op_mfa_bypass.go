@@ -0,0 +1,125 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirokta
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/samber/lo"
+
+	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/directory"
+)
+
+// tacFactorType is the factor type of Okta's temporary access code, which
+// lets a user sign in once in place of their usual MFA device.
+const tacFactorType = "tac"
+
+const defaultMFABypassCodeTTL = 24 * time.Hour
+
+// tacUserFactor is the request and response body for enrolling a temporary
+// access code. The SDK does not model this factor type, but the enroll
+// endpoint accepts any okta.Factor.
+type tacUserFactor struct {
+	FactorType string                 `json:"factorType,omitempty"`
+	Provider   string                 `json:"provider,omitempty"`
+	ID         string                 `json:"id,omitempty"`
+	Status     string                 `json:"status,omitempty"`
+	Profile    *tacUserFactorProfile  `json:"profile,omitempty"`
+	Embedded   *tacUserFactorEmbedded `json:"_embedded,omitempty"`
+}
+
+type tacUserFactorProfile struct {
+	// TTL is the lifetime of the code in minutes.
+	TTL      int  `json:"ttl"`
+	MultiUse bool `json:"multiUse"`
+}
+
+type tacUserFactorEmbedded struct {
+	TAC       string     `json:"tac"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+func (f *tacUserFactor) IsUserFactorInstance() bool {
+	return true
+}
+
+func (p *Provider) performOperationGetMFABypassCode(ctx context.Context, req diragentapi.DirAgentPerformOperationRequest) (*diragentapi.DirAgentPerformOperationResponse, error) {
+	ctx, oktaClient, err := p.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	supported, _, err := oktaClient.UserFactor.ListSupportedFactors(ctx, req.AccountImmutableID)
+	if err != nil {
+		return nil, p.filterAPIError(err)
+	}
+	if !lo.ContainsBy(supported, func(f okta.Factor) bool {
+		uf, ok := f.(*okta.UserFactor)
+		return ok && uf.FactorType == tacFactorType
+	}) {
+		return nil, directory.CodedError{
+			Code:    diragentapi.UnsupportedAccountState,
+			Message: "temporary access codes are not enabled for this account",
+		}
+	}
+
+	if lo.FromPtr(req.DryRun) {
+		return &diragentapi.DirAgentPerformOperationResponse{}, nil
+	}
+
+	// Okta allows a single temporary access code per user, so replace any
+	// code that was issued previously.
+	factors, _, err := oktaClient.UserFactor.ListFactors(ctx, req.AccountImmutableID)
+	if err != nil {
+		return nil, p.filterAPIError(err)
+	}
+	for _, f := range factors {
+		uf, ok := f.(*okta.UserFactor)
+		if !ok || uf.FactorType != tacFactorType {
+			continue
+		}
+		if _, err := oktaClient.UserFactor.DeleteFactor(ctx, req.AccountImmutableID, uf.Id); err != nil {
+			return nil, p.filterAPIError(err)
+		}
+	}
+
+	ttl := p.MFABypassCodeTTL
+	if ttl == 0 {
+		ttl = defaultMFABypassCodeTTL
+	}
+	enrolled, _, err := oktaClient.UserFactor.EnrollFactor(ctx, req.AccountImmutableID, &tacUserFactor{
+		FactorType: tacFactorType,
+		Provider:   "OKTA",
+		Profile: &tacUserFactorProfile{
+			TTL:      int(ttl / time.Minute),
+			MultiUse: false,
+		},
+	}, nil)
+	if err != nil {
+		return nil, p.filterAPIError(err)
+	}
+	tac, ok := enrolled.(*tacUserFactor)
+	if !ok || tac.Embedded == nil || tac.Embedded.TAC == "" {
+		return nil, fmt.Errorf("okta: temporary access code missing from enroll response")
+	}
+
+	return &diragentapi.DirAgentPerformOperationResponse{
+		MfaBypassCode: &tac.Embedded.TAC,
+	}, nil
+}
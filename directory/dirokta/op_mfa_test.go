@@ -0,0 +1,125 @@
+// Copyright 2025 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirokta
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/samber/lo"
+
+	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/directory"
+)
+
+func TestGetMFALink(t *testing.T) {
+	for _, test := range []struct {
+		status   string
+		want     string
+		wantErr  bool
+		requests []string
+	}{
+		{
+			status:   "STAGED",
+			want:     "https://example.okta.com/activate",
+			requests: []string{"POST /api/v1/users/u1/lifecycle/activate"},
+		},
+		{
+			status:   "PROVISIONED",
+			want:     "https://example.okta.com/activate",
+			requests: []string{"POST /api/v1/users/u1/lifecycle/reactivate"},
+		},
+		{
+			status: "ACTIVE",
+			want:   "https://example.okta.com/reset",
+			requests: []string{
+				"POST /api/v1/users/u1/lifecycle/reset_factors",
+				"POST /api/v1/users/u1/lifecycle/reset_password",
+			},
+		},
+		{
+			status: "LOCKED_OUT",
+			want:   "https://example.okta.com/reset",
+			requests: []string{
+				"POST /api/v1/users/u1/lifecycle/reset_factors",
+				"POST /api/v1/users/u1/lifecycle/reset_password",
+			},
+		},
+		{status: "SUSPENDED", wantErr: true},
+		{status: "DEPROVISIONED", wantErr: true},
+	} {
+		org := newTestOkta(t)
+		org.Handle("GET /api/v1/users/{id}", func(r *http.Request) any {
+			return okta.User{Id: r.PathValue("id"), Status: test.status}
+		})
+		sendEmail := func(r *http.Request) {
+			if got := r.URL.Query().Get("sendEmail"); got != "false" {
+				t.Errorf("%s: %s: got sendEmail=%q, want false", test.status, r.URL.Path, got)
+			}
+		}
+		for _, path := range []string{"activate", "reactivate"} {
+			org.Handle("POST /api/v1/users/{id}/lifecycle/"+path, func(r *http.Request) any {
+				sendEmail(r)
+				return okta.UserActivationToken{ActivationUrl: "https://example.okta.com/activate"}
+			})
+		}
+		org.Handle("POST /api/v1/users/{id}/lifecycle/reset_factors", func(r *http.Request) any {
+			return map[string]any{}
+		})
+		org.Handle("POST /api/v1/users/{id}/lifecycle/reset_password", func(r *http.Request) any {
+			sendEmail(r)
+			return okta.ResetPasswordToken{ResetPasswordUrl: "https://example.okta.com/reset"}
+		})
+		p := org.Provider()
+
+		// a dry run changes nothing
+		_, err := p.performOperationGetMFALink(context.Background(), diragentapi.DirAgentPerformOperationRequest{
+			AccountImmutableID: "u1",
+			DryRun:             lo.ToPtr(true),
+		})
+		if got := org.Requests(); len(got) != 1 {
+			t.Errorf("%s: dry run: got requests %v", test.status, got)
+		}
+		if test.wantErr {
+			var coded directory.CodedError
+			if !errors.As(err, &coded) || coded.Code != diragentapi.UnsupportedAccountState {
+				t.Errorf("%s: got %v, want %s", test.status, err, diragentapi.UnsupportedAccountState)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: dry run: %v", test.status, err)
+			continue
+		}
+
+		resp, err := p.performOperationGetMFALink(context.Background(), diragentapi.DirAgentPerformOperationRequest{
+			AccountImmutableID: "u1",
+		})
+		if err != nil {
+			t.Errorf("%s: %v", test.status, err)
+			continue
+		}
+		if got := lo.FromPtr(resp.MfaResetLink); got != test.want {
+			t.Errorf("%s: got link %q, want %q", test.status, got, test.want)
+		}
+		if got := org.Requests()[2:]; !slices.Equal(got, test.requests) {
+			t.Errorf("%s: got requests %v, want %v", test.status, got, test.requests)
+		}
+	}
+}
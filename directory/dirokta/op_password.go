@@ -16,11 +16,13 @@ package dirokta
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/okta/okta-sdk-golang/v2/okta/query"
 	"github.com/samber/lo"
 
 	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/directory"
 )
 
 func (p *Provider) performOperationGetPasswordLink(ctx context.Context, req diragentapi.DirAgentPerformOperationRequest) (*diragentapi.DirAgentPerformOperationResponse, error) {
@@ -42,3 +44,39 @@ func (p *Provider) performOperationGetPasswordLink(ctx context.Context, req dira
 		PasswordLink: &resetPasswordToken.ResetPasswordUrl,
 	}, nil
 }
+
+func (p *Provider) performOperationGetTemporaryPassword(ctx context.Context, req diragentapi.DirAgentPerformOperationRequest) (*diragentapi.DirAgentPerformOperationResponse, error) {
+	ctx, client, err := p.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	u, _, err := client.User.GetUser(ctx, req.AccountImmutableID)
+	if err != nil {
+		return nil, p.filterAPIError(err)
+	}
+	switch u.Status {
+	case "ACTIVE", "PASSWORD_EXPIRED", "RECOVERY", "LOCKED_OUT":
+	default:
+		return nil, directory.CodedError{
+			Code:    diragentapi.UnsupportedAccountState,
+			Message: fmt.Sprintf("cannot set a temporary password for an account with status %s", u.Status),
+		}
+	}
+	if lo.FromPtr(req.DryRun) {
+		return &diragentapi.DirAgentPerformOperationResponse{}, nil
+	}
+
+	// https://developer.okta.com/docs/reference/api/users/#expire-password-with-temporary-password
+	tempPassword, _, err := client.User.ExpirePasswordAndGetTemporaryPassword(ctx, req.AccountImmutableID)
+	if err != nil {
+		return nil, p.filterAPIError(err)
+	}
+	if tempPassword.TempPassword == "" {
+		return nil, fmt.Errorf("okta: temporary password missing from response")
+	}
+
+	return &diragentapi.DirAgentPerformOperationResponse{
+		TemporaryPassword: &tempPassword.TempPassword,
+	}, nil
+}
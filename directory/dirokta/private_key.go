@@ -0,0 +1,87 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirokta
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// parsePrivateKey parses an RSA or EC private key in PEM (PKCS#1, SEC 1 or
+// PKCS#8) or JWK form and returns the JWT signing method that goes with it.
+// If the key is a JWK with a "kid", that key ID is returned too.
+func parsePrivateKey(data string) (crypto.Signer, jwt.SigningMethod, string, error) {
+	data = strings.TrimSpace(data)
+
+	var key any
+	var keyID string
+	if strings.HasPrefix(data, "{") {
+		var jwk jose.JSONWebKey
+		if err := json.Unmarshal([]byte(data), &jwk); err != nil {
+			return nil, nil, "", fmt.Errorf("cannot parse okta private key as JWK: %w", err)
+		}
+		if jwk.IsPublic() {
+			return nil, nil, "", fmt.Errorf("okta private key JWK does not contain a private key")
+		}
+		key = jwk.Key
+		keyID = jwk.KeyID
+	} else {
+		block, _ := pem.Decode([]byte(data))
+		if block == nil {
+			return nil, nil, "", fmt.Errorf("okta private key must be a PEM encoded key or a JWK")
+		}
+		var err error
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		default:
+			return nil, nil, "", fmt.Errorf("unsupported okta private key type %q", block.Type)
+		}
+		if err != nil {
+			return nil, nil, "", fmt.Errorf("cannot parse okta private key: %w", err)
+		}
+	}
+
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		return key, jwt.SigningMethodRS256, keyID, nil
+	case *ecdsa.PrivateKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return key, jwt.SigningMethodES256, keyID, nil
+		case elliptic.P384():
+			return key, jwt.SigningMethodES384, keyID, nil
+		case elliptic.P521():
+			return key, jwt.SigningMethodES512, keyID, nil
+		}
+		return nil, nil, "", fmt.Errorf("unsupported okta private key curve %s", key.Curve.Params().Name)
+	default:
+		return nil, nil, "", fmt.Errorf("okta private key must be an RSA or EC key, got %T", key)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirokta
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/okta/okta-sdk-golang/v2/okta"
+
+	"github.com/nametaginc/cli/diragentapi"
+)
+
+var (
+	defaultIDAttributes       = []string{"login", "email", "secondEmail"}
+	defaultNameTemplate       = "{firstName} {lastName}"
+	defaultBirthDateAttribute = "birthdate"
+)
+
+// nameTemplatePlaceholder matches the {attribute} placeholders in NameTemplate.
+var nameTemplatePlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// profileAttributeName matches valid Okta profile attribute names.
+var profileAttributeName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (p *Provider) idAttributes() []string {
+	if len(p.IDAttributes) > 0 {
+		return p.IDAttributes
+	}
+	return defaultIDAttributes
+}
+
+func (p *Provider) birthDateAttribute() string {
+	if p.BirthDateAttribute != "" {
+		return p.BirthDateAttribute
+	}
+	return defaultBirthDateAttribute
+}
+
+// validateProfileMapping checks the configured profile attribute names. The
+// ID attributes end up in search expressions, so reject anything that is not
+// a plain attribute name.
+func (p *Provider) validateProfileMapping() error {
+	attributes := append([]string{}, p.idAttributes()...)
+	if p.NameAttribute != "" {
+		attributes = append(attributes, p.NameAttribute)
+	}
+	if p.NameTemplate != "" {
+		for _, m := range nameTemplatePlaceholder.FindAllStringSubmatch(p.NameTemplate, -1) {
+			attributes = append(attributes, m[1])
+		}
+	}
+	attributes = append(attributes, p.birthDateAttribute())
+	for _, attribute := range attributes {
+		if !profileAttributeName.MatchString(attribute) {
+			return fmt.Errorf("invalid okta profile attribute %q", attribute)
+		}
+	}
+	if p.NameAttribute != "" && p.NameTemplate != "" {
+		return fmt.Errorf("only one of the okta name attribute and name template may be set")
+	}
+	return nil
+}
+
+// account returns the account for user, without group information.
+func (p *Provider) account(user *okta.User) diragentapi.DirAgentAccount {
+	account := diragentapi.DirAgentAccount{
+		ImmutableID: user.Id,
+		IDs:         p.externalIDs(user),
+		Name:        p.displayName(user),
+		UpdatedAt:   user.LastUpdated,
+	}
+	if birthDate := profileString(user, p.birthDateAttribute()); birthDate != "" {
+		account.BirthDate = &birthDate
+	}
+	return account
+}
+
+func (p *Provider) externalIDs(user *okta.User) []string {
+	var rv []string
+	for _, fieldName := range p.idAttributes() {
+		if value := profileString(user, fieldName); value != "" {
+			rv = append(rv, value)
+		}
+	}
+	return rv
+}
+
+// displayName returns the user's name, either from NameAttribute or by
+// expanding NameTemplate, e.g. "{firstName} {lastName}".
+func (p *Provider) displayName(user *okta.User) string {
+	if p.NameAttribute != "" {
+		return strings.TrimSpace(profileString(user, p.NameAttribute))
+	}
+	template := p.NameTemplate
+	if template == "" {
+		template = defaultNameTemplate
+	}
+	name := nameTemplatePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		return profileString(user, strings.Trim(placeholder, "{}"))
+	})
+	return strings.Join(strings.Fields(name), " ")
+}
+
+func profileString(user *okta.User, fieldName string) string {
+	if user.Profile == nil {
+		return ""
+	}
+	value, _ := (*user.Profile)[fieldName].(string)
+	return value
+}
@@ -0,0 +1,180 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirokta
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultRateLimitShare = 0.5
+
+// rateLimitBudget is the rate limit state of one bucket of endpoints: what
+// Okta last reported, less the requests made since.
+type rateLimitBudget struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+
+	// Next is the earliest time the next request may be made.
+	Next time.Time
+}
+
+// rateLimiter is an http.RoundTripper that reads the X-Rate-Limit-* headers
+// from Okta's responses and paces requests so that the agent's share of each
+// limit is spread evenly over the rest of the window, rather than spent at
+// once. Okta's limits are shared by every client of the org, including the
+// admin console, so the agent deliberately leaves the rest of the limit
+// unused.
+//
+// https://developer.okta.com/docs/reference/rl-best-practices/
+type rateLimiter struct {
+	next http.RoundTripper
+
+	// share is the fraction of each rate limit the agent may use, in (0, 1].
+	share float64
+
+	mu      sync.Mutex
+	budgets map[string]*rateLimitBudget
+}
+
+func newRateLimiter(next http.RoundTripper, share float64) *rateLimiter {
+	if share <= 0 || share > 1 {
+		share = defaultRateLimitShare
+	}
+	return &rateLimiter{
+		next:    next,
+		share:   share,
+		budgets: map[string]*rateLimitBudget{},
+	}
+}
+
+func (r *rateLimiter) RoundTrip(req *http.Request) (*http.Response, error) {
+	bucket := rateLimitBucket(req)
+	if wait := r.delay(bucket); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	r.update(bucket, resp)
+	return resp, nil
+}
+
+// delay reserves a request to bucket, and returns how long to wait before
+// making it.
+//
+// The requests left in the agent's share are spaced evenly until the window
+// resets, and each request is counted against the budget as it is reserved,
+// so that concurrent requests don't overshoot the share while waiting for
+// Okta to report the new remaining count.
+func (r *rateLimiter) delay(bucket string) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	budget, ok := r.budgets[bucket]
+	if !ok || budget.Limit == 0 {
+		return 0
+	}
+	t := now()
+	untilReset := budget.Reset.Sub(t)
+	if untilReset <= 0 {
+		delete(r.budgets, bucket)
+		return 0
+	}
+
+	reserved := int(float64(budget.Limit) * (1 - r.share))
+	available := budget.Remaining - reserved
+	if available <= 0 {
+		log.Printf("okta: rate limit budget for %s exhausted (%d of %d remaining, %d reserved), waiting %s",
+			bucket, budget.Remaining, budget.Limit, reserved, untilReset.Round(time.Second))
+		return untilReset
+	}
+
+	at := t
+	if budget.Next.After(at) {
+		at = budget.Next
+	}
+	budget.Next = at.Add(untilReset / time.Duration(available))
+	budget.Remaining--
+	return at.Sub(t)
+}
+
+func (r *rateLimiter) update(bucket string, resp *http.Response) {
+	limit, err := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, err := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-Rate-Limit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	budget, ok := r.budgets[bucket]
+	if ok && budget.Reset.Equal(time.Unix(reset, 0)) {
+		// Responses to concurrent requests arrive out of order, and count
+		// none of the requests still in flight, so within a window the
+		// lowest remaining count is the most accurate.
+		budget.Limit = limit
+		budget.Remaining = min(budget.Remaining, remaining)
+		return
+	}
+	r.budgets[bucket] = &rateLimitBudget{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     time.Unix(reset, 0),
+	}
+}
+
+// logRateLimit logs the rate limit state reported in resp, so operators can
+// see how much of the org's limit a sync is using.
+func logRateLimit(resp *http.Response) {
+	if resp == nil || resp.Header.Get("X-Rate-Limit-Limit") == "" {
+		return
+	}
+	log.Printf("okta: rate limit for %s: %s of %s remaining",
+		rateLimitBucket(resp.Request),
+		resp.Header.Get("X-Rate-Limit-Remaining"),
+		resp.Header.Get("X-Rate-Limit-Limit"))
+}
+
+// rateLimitBucket approximates Okta's rate limit buckets, which are mostly
+// per endpoint, e.g. "GET /api/v1/users" and "GET /api/v1/users/{id}" are
+// limited separately.
+func rateLimitBucket(req *http.Request) string {
+	segments := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(segments) > 3 {
+		segments = append(segments[:3], "*")
+	}
+	return req.Method + " /" + strings.Join(segments, "/")
+}
@@ -0,0 +1,84 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirokta
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterPacesRequests(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	defer func() { now = time.Now }()
+	now = func() time.Time { return start }
+
+	r := newRateLimiter(nil, 0.5)
+	bucket := "GET /api/v1/users"
+	if wait := r.delay(bucket); wait != 0 {
+		t.Fatalf("first request: got delay %s, want 0", wait)
+	}
+
+	// 100 of 100 remaining with half reserved leaves 50 requests for the
+	// 60 seconds until the reset, and each request is spaced by the time
+	// left over the requests left.
+	r.update(bucket, &http.Response{Header: http.Header{
+		"X-Rate-Limit-Limit":     {"100"},
+		"X-Rate-Limit-Remaining": {"100"},
+		"X-Rate-Limit-Reset":     {strconv.FormatInt(start.Add(time.Minute).Unix(), 10)},
+	}})
+	for i, want := range []time.Duration{0, time.Minute / 50, time.Minute/50 + time.Minute/49} {
+		if wait := r.delay(bucket); wait != want {
+			t.Errorf("request %d: got delay %s, want %s", i, wait, want)
+		}
+	}
+	if got := r.budgets[bucket].Remaining; got != 97 {
+		t.Errorf("got %d remaining, want 97", got)
+	}
+
+	// a stale response from before the requests were made does not restore
+	// the budget
+	r.update(bucket, &http.Response{Header: http.Header{
+		"X-Rate-Limit-Limit":     {"100"},
+		"X-Rate-Limit-Remaining": {"99"},
+		"X-Rate-Limit-Reset":     {strconv.FormatInt(start.Add(time.Minute).Unix(), 10)},
+	}})
+	if got := r.budgets[bucket].Remaining; got != 97 {
+		t.Errorf("after stale update: got %d remaining, want 97", got)
+	}
+}
+
+func TestRateLimiterWaitsForResetWhenShareIsUsed(t *testing.T) {
+	start := time.Unix(1_700_000_000, 0)
+	defer func() { now = time.Now }()
+	now = func() time.Time { return start }
+
+	r := newRateLimiter(nil, 0.5)
+	bucket := "GET /api/v1/groups"
+	r.update(bucket, &http.Response{Header: http.Header{
+		"X-Rate-Limit-Limit":     {"100"},
+		"X-Rate-Limit-Remaining": {"50"},
+		"X-Rate-Limit-Reset":     {strconv.FormatInt(start.Add(30*time.Second).Unix(), 10)},
+	}})
+	if wait := r.delay(bucket); wait != 30*time.Second {
+		t.Errorf("got delay %s, want 30s", wait)
+	}
+
+	now = func() time.Time { return start.Add(31 * time.Second) }
+	if wait := r.delay(bucket); wait != 0 {
+		t.Errorf("after reset: got delay %s, want 0", wait)
+	}
+}
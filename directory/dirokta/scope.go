@@ -0,0 +1,261 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirokta
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/okta/okta-sdk-golang/v2/okta"
+	"github.com/okta/okta-sdk-golang/v2/okta/query"
+	"github.com/samber/lo"
+
+	"github.com/nametaginc/cli/diragentapi"
+	"github.com/nametaginc/cli/directory"
+)
+
+var userStatuses = []string{
+	"STAGED", "PROVISIONED", "ACTIVE", "RECOVERY", "PASSWORD_EXPIRED",
+	"LOCKED_OUT", "SUSPENDED", "DEPROVISIONED",
+}
+
+// isScoped returns true if the provider only exposes some of the users in the
+// org.
+func (p *Provider) isScoped() bool {
+	return len(p.Groups) > 0 || len(p.Statuses) > 0 || p.Search != ""
+}
+
+func (p *Provider) validateScope() error {
+	for _, status := range p.Statuses {
+		if !lo.Contains(userStatuses, status) {
+			return fmt.Errorf("invalid okta user status %q, expected one of %s",
+				status, strings.Join(userStatuses, ", "))
+		}
+	}
+	// The group members endpoint does not support search expressions, and
+	// we have no way to evaluate one locally.
+	if len(p.Groups) > 0 && p.Search != "" {
+		return fmt.Errorf("okta groups and search expression cannot be combined")
+	}
+	return nil
+}
+
+// userQuery returns the query parameters that select the users in scope that
+// were updated after updatedAfter (if not nil).
+//
+// Okta does not allow filter and search to be combined, so if a custom search
+// expression is configured, every condition goes into the search expression.
+func (p *Provider) userQuery(updatedAfter *time.Time) []query.ParamOptions {
+	var exprs []string
+	if expr := p.scopeSearchExpr(); expr != "" {
+		exprs = append(exprs, expr)
+	}
+	if updatedAfter != nil {
+		exprs = append(exprs, fmt.Sprintf("lastUpdated gt %q", updatedAfter.Format(oktaTimeFormat)))
+	}
+	switch {
+	case len(exprs) == 0:
+		return nil
+	case p.Search != "":
+		return []query.ParamOptions{query.WithSearch(strings.Join(exprs, " and "))}
+	default:
+		return []query.ParamOptions{query.WithFilter(strings.Join(exprs, " and "))}
+	}
+}
+
+// listGroupAccounts lists the members of each of the configured groups in
+// turn. The cursor is the index of the current group and the Okta cursor
+// within that group, separated by a colon.
+//
+// Users that belong to more than one of the groups are returned only with
+// the first of them, so members of earlier groups are skipped.
+func (p *Provider) listGroupAccounts(ctx context.Context, client *okta.Client, req diragentapi.DirAgentListAccountsRequest) (*diragentapi.DirAgentListAccountsResponse, error) {
+	groupIndex, after := 0, ""
+	if req.Cursor != nil {
+		index, rest, ok := strings.Cut(*req.Cursor, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid cursor %q", *req.Cursor)
+		}
+		var err error
+		groupIndex, err = strconv.Atoi(index)
+		if err != nil || groupIndex < 0 || groupIndex >= len(p.Groups) {
+			return nil, fmt.Errorf("invalid cursor %q", *req.Cursor)
+		}
+		after = rest
+	}
+
+	paramOptions := []query.ParamOptions{
+		query.WithLimit(250),
+	}
+	if after != "" {
+		paramOptions = append(paramOptions, query.WithAfter(after))
+	}
+
+	users, resp, err := client.Group.ListGroupUsers(ctx, p.Groups[groupIndex], query.NewQueryParams(paramOptions...))
+	if err != nil {
+		return nil, fmt.Errorf("okta: failed to list group members: %w", err)
+	}
+	logRateLimit(resp.Response)
+
+	if req.Cursor == nil {
+		p.scopeMembers = nil
+	}
+	earlier, err := p.earlierGroupMembers(ctx, client, groupIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := diragentapi.DirAgentListAccountsResponse{}
+	for _, user := range users {
+		if earlier[user.Id] {
+			continue
+		}
+		if len(p.Statuses) > 0 && !lo.Contains(p.Statuses, user.Status) {
+			continue
+		}
+		if req.UpdatedAfter != nil && (user.LastUpdated == nil || !user.LastUpdated.After(*req.UpdatedAfter)) {
+			continue
+		}
+		account := p.account(user)
+		p.idIndex().Set(user.Id, account.IDs)
+		rv.Accounts = append(rv.Accounts, account)
+	}
+	if err := p.addGroups(ctx, client, req.Cursor, rv.Accounts); err != nil {
+		return nil, err
+	}
+
+	nextAfter, err := nextPageCursor(resp)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case nextAfter != "":
+		rv.NextCursor = lo.ToPtr(fmt.Sprintf("%d:%s", groupIndex, nextAfter))
+	case groupIndex+1 < len(p.Groups):
+		rv.NextCursor = lo.ToPtr(fmt.Sprintf("%d:", groupIndex+1))
+	case req.UpdatedAfter != nil:
+		changed, err := p.membershipChangedAccounts(ctx, client, *req.UpdatedAfter)
+		if err != nil {
+			return nil, err
+		}
+		rv.Accounts = append(rv.Accounts, changed...)
+	}
+	return &rv, nil
+}
+
+// earlierGroupMembers returns the IDs of the members of the configured
+// groups before groupIndex, whose members listGroupAccounts has already
+// returned. Members are cached for the rest of the sync, so each group is
+// listed at most once more.
+func (p *Provider) earlierGroupMembers(ctx context.Context, client *okta.Client, groupIndex int) (map[string]bool, error) {
+	if p.scopeMembers == nil {
+		p.scopeMembers = map[string]map[string]bool{}
+	}
+	members := map[string]bool{}
+	for _, groupID := range p.Groups[:groupIndex] {
+		groupMembers, ok := p.scopeMembers[groupID]
+		if !ok {
+			groupMembers = map[string]bool{}
+			users, resp, err := client.Group.ListGroupUsers(ctx, groupID, query.NewQueryParams(query.WithLimit(1000)))
+			for {
+				if err != nil {
+					return nil, fmt.Errorf("okta: failed to list group members: %w", err)
+				}
+				for _, user := range users {
+					groupMembers[user.Id] = true
+				}
+				if !resp.HasNextPage() {
+					break
+				}
+				users = nil
+				resp, err = resp.Next(ctx, &users)
+			}
+			p.scopeMembers[groupID] = groupMembers
+		}
+		for userID := range groupMembers {
+			members[userID] = true
+		}
+	}
+	return members, nil
+}
+
+// scopeSearchExpr returns a search expression that matches the users in
+// scope other than by group membership, or an empty string if there are no
+// such constraints.
+func (p *Provider) scopeSearchExpr() string {
+	var exprs []string
+	if len(p.Statuses) > 0 {
+		exprs = append(exprs, "("+strings.Join(lo.Map(p.Statuses, func(status string, _ int) string {
+			return fmt.Sprintf("status eq %q", status)
+		}), " or ")+")")
+	}
+	if p.Search != "" {
+		exprs = append(exprs, "("+p.Search+")")
+	}
+	return strings.Join(exprs, " and ")
+}
+
+// inGroupScope returns true if the groups include one of the configured
+// groups, or if the provider is not limited to certain groups.
+func (p *Provider) inGroupScope(groups []*okta.Group) bool {
+	if len(p.Groups) == 0 {
+		return true
+	}
+	return lo.ContainsBy(groups, func(group *okta.Group) bool {
+		return lo.Contains(p.Groups, group.Id)
+	})
+}
+
+// checkScope returns an account_not_found error if the account does not
+// exist or is not one of the users exposed by the provider.
+func (p *Provider) checkScope(ctx context.Context, userID string) error {
+	if !p.isScoped() {
+		return nil
+	}
+	ctx, client, err := p.client(ctx)
+	if err != nil {
+		return err
+	}
+	notFound := directory.CodedError{
+		Code:    diragentapi.AccountNotFound,
+		Message: "account not found",
+	}
+
+	if expr := p.scopeSearchExpr(); expr != "" {
+		users, _, err := client.User.ListUsers(ctx, query.NewQueryParams(
+			query.WithLimit(1),
+			query.WithSearch(fmt.Sprintf("(id eq %q) and %s", userID, expr)),
+		))
+		if err != nil {
+			return fmt.Errorf("okta: failed to list users: %w", err)
+		}
+		if len(users) == 0 {
+			return notFound
+		}
+	}
+	if len(p.Groups) > 0 {
+		_, inScope, err := p.accountGroups(ctx, client, userID)
+		if err != nil {
+			return err
+		}
+		if !inScope {
+			return notFound
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2025 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dirokta
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/okta/okta-sdk-golang/v2/okta"
+)
+
+// testOkta is an Okta org for tests, which serves the handlers registered
+// with Handle and records the requests it receives.
+type testOkta struct {
+	t   *testing.T
+	mux *http.ServeMux
+	URL string
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func newTestOkta(t *testing.T) *testOkta {
+	t.Helper()
+	o := &testOkta{t: t, mux: http.NewServeMux()}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o.mu.Lock()
+		o.requests = append(o.requests, r.Method+" "+r.URL.Path)
+		o.mu.Unlock()
+		o.mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	o.URL = server.URL
+	return o
+}
+
+// Handle serves requests matching pattern, e.g. "GET /api/v1/users/{id}",
+// with the JSON encoding of the value that fn returns.
+func (o *testOkta) Handle(pattern string, fn func(r *http.Request) any) {
+	o.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(fn(r)); err != nil {
+			o.t.Error(err)
+		}
+	})
+}
+
+// Requests returns the method and path of every request received so far.
+func (o *testOkta) Requests() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]string(nil), o.requests...)
+}
+
+// Provider returns a provider that talks to the org.
+func (o *testOkta) Provider() *Provider {
+	_, client, err := okta.NewClient(context.Background(),
+		okta.WithOrgUrl(o.URL),
+		okta.WithToken("token"),
+		okta.WithTestingDisableHttpsCheck(true),
+		okta.WithCache(false))
+	if err != nil {
+		o.t.Fatal(err)
+	}
+	return &Provider{URL: o.URL, Token: "token", Client: client}
+}
@@ -18,6 +18,7 @@ package directory
 import (
 	"context"
 	"fmt"
+	"log"
 
 	"github.com/nametaginc/cli/diragentapi"
 )
@@ -54,3 +55,25 @@ type CodedError diragentapi.DirAgentErrorResponse
 func (c CodedError) Error() string {
 	return fmt.Sprintf("%s %s", c.Code, c.Message)
 }
+
+// RefreshCredentialsFunc reads the credentials of a provider again, for
+// example by resolving secret references, and returns true if they changed.
+// Providers call it when the directory rejects their credentials, so that
+// rotated secrets are picked up without restarting the agent.
+type RefreshCredentialsFunc func() (bool, error)
+
+// Refresh calls f, if it is set, and returns true if the credentials changed.
+func (f RefreshCredentialsFunc) Refresh() bool {
+	if f == nil {
+		return false
+	}
+	changed, err := f()
+	if err != nil {
+		log.Printf("ERROR: cannot refresh directory credentials: %s", err)
+		return false
+	}
+	if changed {
+		log.Printf("directory credentials were rejected and have changed, retrying with the new credentials")
+	}
+	return changed
+}
@@ -0,0 +1,224 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pwgen generates temporary passwords that satisfy the length,
+// character class and forbidden string rules of a directory's password
+// policy.
+package pwgen
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// DefaultMinLength is the shortest password generated when no floor length
+// is configured.
+const DefaultMinLength = 12
+
+// maxAttempts is how many passwords are generated before giving up on one
+// that does not contain a forbidden string.
+const maxAttempts = 100
+
+// Character classes. Backslashes, quotes, backticks and pipes are left out
+// of the symbols because they are awkward to type and to quote in scripts.
+const (
+	lowercase = "abcdefghijklmnopqrstuvwxyz"
+	uppercase = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digits    = "0123456789"
+	symbols   = "~!@#$%^&*()_+-={}[]:<>?,./"
+
+	// ambiguous characters are left out in human-friendly mode
+	ambiguous = "0OoIl1|"
+
+	// humanFriendlySymbols are easy to read aloud and find on a keyboard
+	humanFriendlySymbols = "!@#%+=-_?"
+)
+
+// Policy is the part of a directory's password policy that constrains
+// generated passwords.
+type Policy struct {
+	// MinLength and MaxLength bound the length of the password. A zero
+	// MaxLength means there is no maximum.
+	MinLength int
+	MaxLength int
+
+	// MinClasses is the number of character classes (lowercase, uppercase,
+	// digits and symbols) the password must contain. Active Directory
+	// complexity rules require three.
+	MinClasses int
+
+	// Forbidden are strings that the password must not contain, ignoring
+	// case, such as the account name.
+	Forbidden []string
+}
+
+// Options are the preferences of the operator, which apply on top of the
+// directory's policy.
+type Options struct {
+	// MinLength is the floor length of generated passwords. If zero,
+	// DefaultMinLength is used.
+	MinLength int
+
+	// ExcludedCharacters are never used in generated passwords.
+	ExcludedCharacters string
+
+	// HumanFriendly avoids characters that are easily confused when read,
+	// such as 0 and O, and uses a small set of symbols.
+	HumanFriendly bool
+}
+
+// Generate returns a random password that satisfies policy.
+func Generate(policy Policy, opts Options) (string, error) {
+	length := max(policy.MinLength, opts.MinLength)
+	if opts.MinLength == 0 {
+		length = max(length, DefaultMinLength)
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		if policy.MaxLength < policy.MinLength {
+			return "", fmt.Errorf("password policy maximum length %d is less than its minimum length %d",
+				policy.MaxLength, policy.MinLength)
+		}
+		length = policy.MaxLength
+	}
+
+	classes := characterClasses(opts)
+	if len(classes) == 0 {
+		return "", fmt.Errorf("no characters are available for passwords")
+	}
+	if len(classes) < policy.MinClasses {
+		return "", fmt.Errorf("password policy requires %d character classes but only %d are available",
+			policy.MinClasses, len(classes))
+	}
+	if length < len(classes) {
+		// use as many classes as fit, which is at least the required number
+		if length < policy.MinClasses {
+			return "", fmt.Errorf("password length %d is too short for %d character classes", length, policy.MinClasses)
+		}
+		classes = classes[:length]
+	}
+
+	for range maxAttempts {
+		password, err := generate(length, classes)
+		if err != nil {
+			return "", err
+		}
+		if !containsAny(password, policy.Forbidden) {
+			return password, nil
+		}
+	}
+	return "", fmt.Errorf("could not generate a password that does not contain a forbidden string")
+}
+
+// characterClasses returns the non-empty character classes, without
+// excluded characters.
+func characterClasses(opts Options) []string {
+	excluded := opts.ExcludedCharacters
+	classSymbols := symbols
+	if opts.HumanFriendly {
+		excluded += ambiguous
+		classSymbols = humanFriendlySymbols
+	}
+
+	var classes []string
+	for _, class := range []string{lowercase, uppercase, digits, classSymbols} {
+		class = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(excluded, r) {
+				return -1
+			}
+			return r
+		}, class)
+		if class != "" {
+			classes = append(classes, class)
+		}
+	}
+	return classes
+}
+
+// generate returns a random password of the given length with at least one
+// character from each class.
+func generate(length int, classes []string) (string, error) {
+	password := make([]byte, 0, length)
+	for _, class := range classes {
+		c, err := randomChar(class)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	all := strings.Join(classes, "")
+	for len(password) < length {
+		c, err := randomChar(all)
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	// shuffle so the required characters are not always first
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
+
+func randomChar(chars string) (byte, error) {
+	i, err := randomInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i], nil
+}
+
+func randomInt(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
+
+func containsAny(password string, forbidden []string) bool {
+	lower := strings.ToLower(password)
+	for _, s := range forbidden {
+		if s != "" && strings.Contains(lower, strings.ToLower(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ADForbidden returns the strings that Active Directory complexity rules
+// forbid in the password of an account: the account name, and the parts of
+// the display name, if they are at least three characters long.
+func ADForbidden(accountName string, displayName string) []string {
+	var forbidden []string
+	if len(accountName) >= 3 {
+		forbidden = append(forbidden, accountName)
+	}
+	for _, token := range strings.FieldsFunc(displayName, func(r rune) bool {
+		return strings.ContainsRune(",.-_ #\t", r)
+	}) {
+		if len(token) >= 3 {
+			forbidden = append(forbidden, token)
+		}
+	}
+	return forbidden
+}
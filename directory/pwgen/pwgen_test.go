@@ -0,0 +1,133 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pwgen
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func classCount(password string) int {
+	var n int
+	for _, class := range []string{lowercase, uppercase, digits, symbols + humanFriendlySymbols} {
+		if strings.ContainsAny(password, class) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestGenerate(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		policy     Policy
+		opts       Options
+		wantLength int
+		notAny     string
+	}{
+		{"default length", Policy{}, Options{}, DefaultMinLength, ""},
+		{"policy shorter than default", Policy{MinLength: 6}, Options{}, DefaultMinLength, ""},
+		{"policy longer than default", Policy{MinLength: 20}, Options{}, 20, ""},
+		{"configured floor", Policy{MinLength: 6}, Options{MinLength: 8}, 8, ""},
+		{"configured floor below policy", Policy{MinLength: 16}, Options{MinLength: 8}, 16, ""},
+		{"maximum length", Policy{MinLength: 4, MaxLength: 10}, Options{}, 10, ""},
+		{"complexity", Policy{MinLength: 4, MinClasses: 4}, Options{MinLength: 4}, 4, ""},
+		{"excluded characters", Policy{MinClasses: 3}, Options{ExcludedCharacters: "aeiouAEIOU$%"}, DefaultMinLength, "aeiouAEIOU$%"},
+		{"human friendly", Policy{MinClasses: 4}, Options{HumanFriendly: true}, DefaultMinLength, ambiguous + "~$^&*()[]{}<>:,./"},
+		{"no symbols", Policy{MinClasses: 3}, Options{ExcludedCharacters: symbols}, DefaultMinLength, symbols},
+	} {
+		for range 50 {
+			password, err := Generate(test.policy, test.opts)
+			if err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+			if len(password) != test.wantLength {
+				t.Errorf("%s: %q has length %d, want %d", test.name, password, len(password), test.wantLength)
+			}
+			if n := classCount(password); n < test.policy.MinClasses {
+				t.Errorf("%s: %q has %d character classes, want at least %d", test.name, password, n, test.policy.MinClasses)
+			}
+			if test.notAny != "" && strings.ContainsAny(password, test.notAny) {
+				t.Errorf("%s: %q contains one of %q", test.name, password, test.notAny)
+			}
+		}
+	}
+}
+
+func TestGenerateUsesEveryClass(t *testing.T) {
+	// Without a complexity policy, passwords still use every class that
+	// fits, so that they satisfy quality checks the policy doesn't describe.
+	for range 50 {
+		password, err := Generate(Policy{}, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := classCount(password); n != 4 {
+			t.Errorf("%q has %d character classes, want 4", password, n)
+		}
+	}
+}
+
+func TestGenerateForbidden(t *testing.T) {
+	// Short forbidden strings are likely to occur in random passwords, so
+	// this exercises the retries.
+	policy := Policy{MinLength: 30, Forbidden: []string{"a", "B", "7"}}
+	for range 20 {
+		password, err := Generate(policy, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.ContainsAny(strings.ToLower(password), "ab7") {
+			t.Errorf("%q contains a forbidden string", password)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		policy Policy
+		opts   Options
+	}{
+		{"maximum below minimum", Policy{MinLength: 10, MaxLength: 8}, Options{}},
+		{"every character excluded", Policy{}, Options{ExcludedCharacters: lowercase + uppercase + digits + symbols}},
+		{"too few classes available", Policy{MinClasses: 3}, Options{ExcludedCharacters: lowercase + uppercase}},
+		{"too short for the classes", Policy{MinLength: 2, MaxLength: 2, MinClasses: 3}, Options{MinLength: 2}},
+		{"everything forbidden", Policy{Forbidden: []string{"a", "b", "c"}}, Options{ExcludedCharacters: uppercase + digits + symbols + "defghijklmnopqrstuvwxyz"}},
+	} {
+		if password, err := Generate(test.policy, test.opts); err == nil {
+			t.Errorf("%s: got %q, want an error", test.name, password)
+		}
+	}
+}
+
+func TestADForbidden(t *testing.T) {
+	for _, test := range []struct {
+		accountName string
+		displayName string
+		want        []string
+	}{
+		{"jdoe", "John Doe", []string{"jdoe", "John", "Doe"}},
+		{"al", "Al Smith-Jones", []string{"Smith", "Jones"}},
+		{"mary.ann", "Mary Ann O'Neil, Jr.", []string{"mary.ann", "Mary", "Ann", "O'Neil"}},
+		{"svc_backup", "", []string{"svc_backup"}},
+		{"", "Li#Wu\tTest", []string{"Test"}},
+	} {
+		if got := ADForbidden(test.accountName, test.displayName); !slices.Equal(got, test.want) {
+			t.Errorf("ADForbidden(%q, %q) = %q, want %q", test.accountName, test.displayName, got, test.want)
+		}
+	}
+}
@@ -30,7 +30,11 @@ func newAuditCmd() *cobra.Command {
 		Use:   "audit",
 		Short: "View audit logs",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			wsURL, err := url.Parse(getServer(cmd))
+			server, err := getServer(cmd)
+			if err != nil {
+				return err
+			}
+			wsURL, err := url.Parse(server)
 			if err != nil {
 				return err
 			}
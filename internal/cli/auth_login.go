@@ -15,15 +15,17 @@
 package cli
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	"time"
 
-	"github.com/ghodss/yaml"
 	"github.com/jpillora/backoff"
 	"github.com/pkg/browser"
 	"github.com/samber/lo"
@@ -31,6 +33,7 @@ import (
 	"golang.org/x/crypto/nacl/box"
 
 	"github.com/nametaginc/cli/internal/config"
+	"github.com/nametaginc/cli/internal/tokenstore"
 )
 
 func newAuthCmd() *cobra.Command {
@@ -39,6 +42,9 @@ func newAuthCmd() *cobra.Command {
 		Short: "Commands for authenticating to Nametag",
 	}
 	cmd.AddCommand(newAuthLoginCmd())
+	cmd.AddCommand(newAuthLogoutCmd())
+	cmd.AddCommand(newAuthStatusCmd())
+	cmd.AddCommand(newAuthProfilesCmd())
 
 	return cmd
 }
@@ -51,85 +57,206 @@ func newAuthLoginCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "login",
 		Short: "Log in to Nametag",
+		Long: `Log in to Nametag.
+The command shows a URL and opens it in your browser. The URL can also be opened on any
+other device, which is useful on servers and jump hosts:
+    nametag auth login --no-browser
+The command waits until the login is approved, until --timeout elapses, or until you press
+Ctrl-C. Network errors while waiting are retried.
+`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			noBrowser, err := cmd.Flags().GetBool("no-browser")
 			if err != nil {
 				return err
 			}
+			timeout, err := cmd.Flags().GetDuration("timeout")
+			if err != nil {
+				return err
+			}
+			tokenStore, err := cmd.Flags().GetString("token-store")
+			if err != nil {
+				return err
+			}
+			requestedTokenStore := tokenStore
+			tokenStore, err = tokenstore.Select(tokenStore)
+			if err != nil {
+				return err
+			}
+			if tokenStore == tokenstore.Config && requestedTokenStore != tokenstore.Config {
+				fmt.Fprintf(cmd.ErrOrStderr(), "The OS credential store is not available, so the token is kept in plaintext in the configuration file.\n"+
+					"Set $NAMETAG_TOKEN_STORE_PASSPHRASE to keep it in an encrypted file instead.\n")
+			}
 
 			publicKey, privateKey, err := box.GenerateKey(randReader)
 			if err != nil {
 				return err
 			}
 
-			server := getServer(cmd)
+			// keep the rest of the configuration, such as directories
+			cliConfig, err := config.ReadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			profileName, err := cliConfig.ProfileName(cmd)
+			if err != nil {
+				return err
+			}
+
+			// the profile is created if it is not defined yet
+			server, err := cmd.Flags().GetString("server")
+			if err != nil {
+				return err
+			}
+			if server == "" {
+				server = os.Getenv("NAMETAG_SERVER")
+			}
+			if profile := cliConfig.Profile(profileName); server == "" && profile != nil {
+				server = profile.Server
+			}
+			server = lo.CoalesceOrEmpty(server, defaultServer)
 			url := server + "/cli/login/" + base64.RawURLEncoding.EncodeToString(publicKey[:])
-			fmt.Fprintf(cmd.OutOrStdout(), "If your browser does not open automatically, then navigate to the following URL to authenticate to Nametag\n")
+			if noBrowser {
+				fmt.Fprintf(cmd.OutOrStdout(), "Open the following URL on any device to authenticate to Nametag\n")
+			} else {
+				fmt.Fprintf(cmd.OutOrStdout(), "If your browser does not open automatically, then navigate to the following URL to authenticate to Nametag\n")
+			}
 			fmt.Fprintf(cmd.OutOrStdout(), "  %s\n", url)
 			if !noBrowser {
 				_ = browserOpenURL(url)
 			}
 
-			bo := backoff.Backoff{Min: time.Second, Max: 10 * time.Second}
-			for {
-				url := server + "/api/cli/login/" + base64.RawURLEncoding.EncodeToString(publicKey[:])
-				req, err := http.NewRequestWithContext(cmd.Context(), "GET", url, nil)
-				if err != nil {
-					return err
-				}
-				req.Header.Add("Accept", "application/json")
-				resp, err := HTTPClient.Do(req)
-				if err != nil {
-					cmd.Printf("failed to fetch authentication token: %s\n", err)
-					return err
-				}
-				if resp.StatusCode >= 400 {
-					cmd.Printf("failed to fetch authentication token: %s\n", resp.Status)
-					return fmt.Errorf("failed to fetch authentication token: %s", resp.Status)
-				}
-
-				if resp.StatusCode == http.StatusNoContent {
-					if bo.Attempt() > 4 {
-						fmt.Fprintf(cmd.OutOrStdout(), "Authentication timed out. Run this command again to retry authentication.\n")
-						os.Exit(1)
-					}
-
-					time.Sleep(bo.Duration())
-					continue
-				}
-
-				encryptedResp, err := io.ReadAll(resp.Body)
-				if err != nil {
-					return err
-				}
-
-				plaintextToken, ok := box.OpenAnonymous(nil, encryptedResp, publicKey, privateKey)
-				if !ok {
-					return err
-				}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
 
-				configPath, err := config.GetPath(cmd)
-				if err != nil {
-					return err
+			plaintextToken, err := waitForLogin(ctx, cmd.ErrOrStderr(), server, publicKey, privateKey)
+			if errors.Is(err, context.DeadlineExceeded) {
+				var pollErr *loginPollError
+				if errors.As(err, &pollErr) {
+					return fmt.Errorf("authentication timed out after %s, the last attempt failed: %w. Run this command again to retry authentication", timeout, pollErr.Err)
 				}
+				return fmt.Errorf("authentication timed out after %s. Run this command again to retry authentication", timeout)
+			}
+			if errors.Is(err, context.Canceled) {
+				return fmt.Errorf("authentication cancelled")
+			}
+			if err != nil {
+				return err
+			}
 
-				config := config.Config{
-					Version: "1",
-					Server:  lo.If(server == defaultServer, "").Else(server),
-					Token:   string(plaintextToken),
-				}
-				configBuf, err := yaml.Marshal(config)
-				if err != nil {
-					return err
-				}
-				if err := os.WriteFile(configPath, configBuf, 0600); err != nil {
-					return err
-				}
-				fmt.Fprintf(cmd.OutOrStdout(), "You are now logged in. You can run other nametag commands now.\n")
+			cliConfig.SetProfile(profileName, &config.Profile{
+				Server:     lo.If(server == defaultServer, "").Else(server),
+				Token:      string(plaintextToken),
+				TokenStore: lo.If(tokenStore == tokenstore.Config, "").Else(tokenStore),
+			})
+			// the first profile becomes the current one
+			if cliConfig.CurrentProfile == "" && cliConfig.Profile(config.DefaultProfile) == nil {
+				cliConfig.CurrentProfile = profileName
+			}
+			if err := config.WriteConfig(cmd, cliConfig); err != nil {
+				return err
+			}
+			if profileName != config.DefaultProfile {
+				fmt.Fprintf(cmd.OutOrStdout(), "You are now logged in to %s with profile %q.\n", server, profileName)
 				return nil
 			}
+			fmt.Fprintf(cmd.OutOrStdout(), "You are now logged in. You can run other nametag commands now.\n")
+			return nil
 		},
 	}
 	cmd.Flags().Bool("no-browser", false, "Disable automatic browser opening")
+	cmd.Flags().Duration("timeout", 10*time.Minute, "How long to wait for the login to be approved")
+	cmd.Flags().String("server", "", "Nametag server to log in to, default $NAMETAG_SERVER or the server of the profile")
+	cmd.Flags().String("token-store", lo.CoalesceOrEmpty(os.Getenv("NAMETAG_TOKEN_STORE"), tokenstore.Auto),
+		"Where to store the authentication token: auto, keyring (the OS credential store), file (a file encrypted with $NAMETAG_TOKEN_STORE_PASSPHRASE) or config (the configuration file) ($NAMETAG_TOKEN_STORE)")
 	return cmd
 }
+
+// waitForLogin polls the server until the login is approved, and returns
+// the decrypted authentication token. It shows a progress indicator on
+// progressOut while it waits.
+func waitForLogin(ctx context.Context, progressOut io.Writer, server string, publicKey *[32]byte, privateKey *[32]byte) ([]byte, error) {
+	progress := newSpinner(progressOut, "Waiting for authentication")
+	defer progress.Stop()
+
+	bo := backoff.Backoff{Min: time.Second, Max: 5 * time.Second}
+	url := server + "/api/cli/login/" + base64.RawURLEncoding.EncodeToString(publicKey[:])
+	// lastErr is the error of the last poll if it failed, which is reported
+	// if the login times out
+	var lastErr error
+	for {
+		encryptedResp, err := pollLogin(ctx, url)
+		var pollErr *loginPollError
+		switch {
+		case errors.As(err, &pollErr):
+			Log.Printf("%s, retrying", pollErr)
+			lastErr = pollErr
+		case err != nil && ctx.Err() != nil && lastErr != nil:
+			return nil, fmt.Errorf("%w: %w", ctx.Err(), lastErr)
+		case err != nil:
+			return nil, err
+		case encryptedResp != nil:
+			plaintextToken, ok := box.OpenAnonymous(nil, encryptedResp, publicKey, privateKey)
+			if !ok {
+				return nil, fmt.Errorf("cannot decrypt authentication token")
+			}
+			return plaintextToken, nil
+		default:
+			lastErr = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return nil, fmt.Errorf("%w: %w", ctx.Err(), lastErr)
+			}
+			return nil, ctx.Err()
+		case <-time.After(bo.Duration()):
+		}
+	}
+}
+
+// loginPollError is a failure to poll for the authentication token that is
+// likely to be temporary, such as a network error, and is retried.
+type loginPollError struct {
+	Err error
+}
+
+func (e *loginPollError) Error() string {
+	return fmt.Sprintf("failed to fetch authentication token: %s", e.Err)
+}
+
+func (e *loginPollError) Unwrap() error {
+	return e.Err
+}
+
+// pollLogin fetches the encrypted authentication token, which is nil if
+// the login has not been approved yet. Network errors and server errors are
+// returned as *loginPollError.
+func pollLogin(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+	resp, err := HTTPClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &loginPollError{Err: err}
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &loginPollError{Err: errors.New(resp.Status)}
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("failed to fetch authentication token: %s", resp.Status)
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	return io.ReadAll(resp.Body)
+}
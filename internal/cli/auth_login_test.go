@@ -0,0 +1,118 @@
+// Copyright 2024 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/nacl/box"
+)
+
+// loginServer serves the login polling endpoint. respond is called for each
+// poll with the number of the poll, starting at 1.
+func loginServer(t *testing.T, respond func(n int32, w http.ResponseWriter)) string {
+	t.Helper()
+	var polls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/api/cli/login/") {
+			http.NotFound(w, r)
+			return
+		}
+		respond(polls.Add(1), w)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// dropConnection closes the connection without responding.
+func dropConnection(t *testing.T, w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	_ = conn.Close()
+}
+
+func TestWaitForLoginRetries(t *testing.T) {
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := box.SealAnonymous(nil, []byte("token"), publicKey, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := loginServer(t, func(n int32, w http.ResponseWriter) {
+		switch n {
+		case 1:
+			w.WriteHeader(http.StatusNoContent)
+		case 2:
+			dropConnection(t, w)
+		case 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			_, _ = w.Write(sealed)
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	token, err := waitForLogin(ctx, io.Discard, server, publicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(token) != "token" {
+		t.Errorf("got token %q, want %q", token, "token")
+	}
+}
+
+func TestWaitForLoginErrors(t *testing.T) {
+	publicKey, privateKey, err := box.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a client error is not retried
+	server := loginServer(t, func(n int32, w http.ResponseWriter) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	_, err = waitForLogin(context.Background(), io.Discard, server, publicKey, privateKey)
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("got %v, want a 404 error", err)
+	}
+
+	// the last network error is reported when the login times out
+	server = loginServer(t, func(n int32, w http.ResponseWriter) {
+		dropConnection(t, w)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	_, err = waitForLogin(ctx, io.Discard, server, publicKey, privateKey)
+	var pollErr *loginPollError
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &pollErr) {
+		t.Errorf("got %v, want a timeout after a network error", err)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+
+	"github.com/nametaginc/cli/internal/config"
+	"github.com/nametaginc/cli/internal/tokenstore"
+)
+
+func newAuthProfilesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profiles",
+		Short: "Commands for managing authentication profiles",
+		Long: `Commands for managing authentication profiles.
+A profile is a Nametag server and the token used to authenticate to it. Use profiles to
+switch between Nametag organizations, for example staging and production:
+    nametag auth login --profile staging --server https://staging.example.com
+    nametag auth login --profile production
+    nametag auth profiles use staging
+Other commands use the current profile, unless another one is selected with --profile or
+the NAMETAG_PROFILE environment variable.
+`,
+	}
+	cmd.AddCommand(newAuthProfilesListCmd())
+	cmd.AddCommand(newAuthProfilesUseCmd())
+	return cmd
+}
+
+// profileListItem is a profile as shown by `auth profiles list`.
+type profileListItem struct {
+	Name       string `json:"name"`
+	Server     string `json:"server"`
+	TokenStore string `json:"token_store"`
+	Current    bool   `json:"current"`
+}
+
+func newAuthProfilesListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "list profiles",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliConfig, err := config.ReadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			current, err := cliConfig.ProfileName(cmd)
+			if err != nil {
+				return err
+			}
+
+			names := lo.Keys(cliConfig.Profiles)
+			slices.Sort(names)
+			profiles := []profileListItem{}
+			for _, name := range names {
+				profile := cliConfig.Profiles[name]
+				profiles = append(profiles, profileListItem{
+					Name:       name,
+					Server:     lo.CoalesceOrEmpty(profile.Server, defaultServer),
+					TokenStore: lo.CoalesceOrEmpty(profile.TokenStore, tokenstore.Config),
+					Current:    name == current,
+				})
+			}
+			return printResult(cmd, result{
+				Value:   profiles,
+				Columns: []string{"Name", "Server", "Token Store", "Current"},
+				Rows: lo.Map(profiles, func(p profileListItem, _ int) []string {
+					return []string{p.Name, p.Server, p.TokenStore, lo.If(p.Current, "*").Else("")}
+				}),
+			})
+		},
+	}
+	addOutputFlag(cmd)
+	return cmd
+}
+
+func newAuthProfilesUseCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "use [profile]",
+		Short: "Set the current profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			cliConfig, err := config.ReadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if cliConfig.Profile(name) == nil {
+				return fmt.Errorf("profile %q is not defined. Run `nametag auth login --profile %s` to create it", name, name)
+			}
+
+			cliConfig.CurrentProfile = lo.If(name == config.DefaultProfile, "").Else(name)
+			if err := config.WriteConfig(cmd, cliConfig); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Now using profile %q.\n", name)
+			return nil
+		},
+	}
+	return cmd
+}
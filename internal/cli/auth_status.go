@@ -0,0 +1,168 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nametaginc/cli/internal/config"
+)
+
+// authStatus is the output of `auth status`.
+type authStatus struct {
+	Server      string     `json:"server"`
+	Profile     string     `json:"profile"`
+	TokenSource string     `json:"token_source"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Expired     bool       `json:"expired,omitempty"`
+	Org         string     `json:"org,omitempty"`
+	Role        string     `json:"role,omitempty"`
+}
+
+func newAuthStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the authentication status",
+		Long: `Show the server and profile in use, where the authentication token comes from, when it
+expires, and the organization and role it grants. Exits with an error if there is no valid token.
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profileName, _, err := getProfile(cmd)
+			if err != nil {
+				return err
+			}
+			server, err := getServer(cmd)
+			if err != nil {
+				return err
+			}
+			t, err := findAuthToken(cmd)
+			if err != nil {
+				return err
+			}
+
+			status := authStatus{
+				Server:  server,
+				Profile: profileName,
+			}
+			r := result{
+				Value:   &status,
+				Columns: []string{"Server", "Profile", "TokenSource"},
+				Object:  true,
+			}
+			row := []string{status.Server, status.Profile}
+			printStatus := func(err error) error {
+				r.Rows = [][]string{row}
+				if printErr := printResult(cmd, r); printErr != nil {
+					return printErr
+				}
+				return err
+			}
+
+			if t.Token == "" {
+				status.TokenSource = "none"
+				row = append(row, status.TokenSource)
+				return printStatus(errNoAuthToken)
+			}
+			status.TokenSource = authTokenSourceDescription(t)
+			row = append(row, status.TokenSource)
+			if t.ExpiresAt != nil {
+				status.ExpiresAt = t.ExpiresAt
+				status.Expired = t.Expired()
+				r.Columns = append(r.Columns, "ExpiresAt")
+				if status.Expired {
+					row = append(row, fmt.Sprintf("%s (expired)", t.ExpiresAt.Format(time.RFC3339)))
+					return printStatus(errAuthTokenExpired)
+				}
+				row = append(row, fmt.Sprintf("%s (in %s)", t.ExpiresAt.Format(time.RFC3339),
+					time.Until(*t.ExpiresAt).Round(time.Minute)))
+			}
+
+			client, err := NewAPIClient(cmd)
+			if err != nil {
+				return err
+			}
+			resp, err := client.GetOrgWithResponse(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode() != 200 {
+				return fmt.Errorf("cannot get organization: %s", resp.Status())
+			}
+			status.Org = resp.JSON200.Name
+			status.Role = string(resp.JSON200.Role)
+			r.Columns = append(r.Columns, "Org", "Role")
+			row = append(row, status.Org, status.Role)
+			return printStatus(nil)
+		},
+	}
+	addOutputFlag(cmd)
+	return cmd
+}
+
+// authTokenSourceDescription describes where t came from.
+func authTokenSourceDescription(t *authToken) string {
+	switch t.Source {
+	case authTokenSourceFlag:
+		return "flag (--auth-token)"
+	case authTokenSourceEnv:
+		return "env ($NAMETAG_AUTH_TOKEN)"
+	default:
+		if t.TokenStore != "" {
+			return fmt.Sprintf("config (%s token store)", t.TokenStore)
+		}
+		return "config"
+	}
+}
+
+func newAuthLogoutCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logout",
+		Short: "Log out of Nametag",
+		Long: `Log out of Nametag by removing the authentication token of the profile from the
+configuration file and token store. The profile itself, including its server, is kept.
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliConfig, err := config.ReadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			profileName, err := cliConfig.ProfileName(cmd)
+			if err != nil {
+				return err
+			}
+			profile := cliConfig.Profile(profileName)
+			if profile == nil || (profile.Token == "" && profile.TokenStore == "") {
+				fmt.Fprintf(cmd.OutOrStdout(), "You are not logged in with profile %q.\n", profileName)
+				return nil
+			}
+
+			if err := config.DeleteToken(cmd, cliConfig, profileName); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "You are now logged out of profile %q.\n", profileName)
+			if os.Getenv("NAMETAG_AUTH_TOKEN") != "" {
+				fmt.Fprintf(cmd.ErrOrStderr(), "Note: $NAMETAG_AUTH_TOKEN is still set, and will be used instead of the profile.\n")
+			}
+			return nil
+		},
+	}
+	return cmd
+}
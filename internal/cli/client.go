@@ -16,17 +16,17 @@ package cli
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 
 	"github.com/nametaginc/cli/internal/api"
 	"github.com/nametaginc/cli/internal/config"
+	"github.com/nametaginc/cli/internal/secret"
 )
 
 // NewAPIClient returns a new API client configured as appropriate given the
@@ -37,7 +37,11 @@ func NewAPIClient(cmd *cobra.Command) (*api.ClientWithResponses, error) {
 		return nil, err
 	}
 
-	client, err := api.NewClientWithResponses(getServer(cmd), api.WithRequestEditorFn(
+	server, err := getServer(cmd)
+	if err != nil {
+		return nil, err
+	}
+	client, err := api.NewClientWithResponses(server, api.WithRequestEditorFn(
 		func(ctx context.Context, req *http.Request) error {
 			req.Header.Add("Authorization", "Bearer "+authToken)
 			return nil
@@ -51,35 +55,97 @@ func NewAPIClient(cmd *cobra.Command) (*api.ClientWithResponses, error) {
 	return client, nil
 }
 
-func getAuthToken(cmd *cobra.Command) (authToken string, err error) {
-	authToken, err = cmd.Flags().GetString("auth-token")
+// Errors returned by getAuthToken.
+var (
+	errNoAuthToken      = errors.New("cannot find an authentication token. Do you need to run `nametag auth login`?")
+	errAuthTokenExpired = errors.New("your authentication token has expired. Do you need to run `nametag auth login` again?")
+)
+
+// Sources of authentication tokens.
+const (
+	authTokenSourceFlag   = "flag"
+	authTokenSourceEnv    = "env"
+	authTokenSourceConfig = "config"
+)
+
+// authToken is an authentication token and where it was found.
+type authToken struct {
+	Token string
+
+	// Source is authTokenSourceFlag, authTokenSourceEnv or
+	// authTokenSourceConfig.
+	Source string
+
+	// Profile is the profile the token belongs to, if it came from the
+	// configuration file, and TokenStore is where the profile keeps it.
+	Profile    string
+	TokenStore string
+
+	// ExpiresAt is the expiry time of the token, if it is a JWT with one.
+	ExpiresAt *time.Time
+}
+
+// Expired returns true if the token has an expiry time in the past.
+func (t *authToken) Expired() bool {
+	return t.ExpiresAt != nil && time.Now().After(*t.ExpiresAt)
+}
+
+// findAuthToken returns the authentication token from the --auth-token
+// flag, $NAMETAG_AUTH_TOKEN or the selected profile, in that order. The
+// token is empty if none is found.
+func findAuthToken(cmd *cobra.Command) (*authToken, error) {
+	flagToken, err := cmd.Flags().GetString("auth-token")
 	if err != nil {
-		return "", err
-	}
-	if authToken == "" {
-		authToken = os.Getenv("NAMETAG_AUTH_TOKEN")
+		return nil, err
 	}
-	if authToken == "" {
+
+	var t authToken
+	switch {
+	case flagToken != "":
+		t.Token, t.Source = flagToken, authTokenSourceFlag
+	case os.Getenv("NAMETAG_AUTH_TOKEN") != "":
+		t.Token, t.Source = os.Getenv("NAMETAG_AUTH_TOKEN"), authTokenSourceEnv
+	default:
 		cliConfig, err := config.ReadConfig(cmd)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
-		authToken = cliConfig.Token
+		name, profile, err := getProfile(cmd)
+		if err != nil {
+			return nil, err
+		}
+		if t.Token, err = cliConfig.ProfileToken(name); err != nil {
+			return nil, err
+		}
+		t.Source = authTokenSourceConfig
+		t.Profile, t.TokenStore = name, profile.TokenStore
+	}
+	if t.Token, err = secret.Resolve(t.Token); err != nil {
+		return nil, err
 	}
 
-	if authToken != "" {
+	if t.Token != "" {
 		var claims jwt.RegisteredClaims
-		_, _, err := new(jwt.Parser).ParseUnverified(authToken, &claims)
-		if err == nil && time.Now().After(lo.FromPtr(claims.ExpiresAt).Time) {
-			fmt.Fprintf(cmd.ErrOrStderr(), "Your authentication token has expired. Do you need to run `nametag auth login` again?\n")
-			os.Exit(1)
+		_, _, err := new(jwt.Parser).ParseUnverified(t.Token, &claims)
+		if err == nil && claims.ExpiresAt != nil {
+			t.ExpiresAt = &claims.ExpiresAt.Time
 		}
 	}
+	return &t, nil
+}
 
-	if authToken == "" {
-		fmt.Fprintf(cmd.ErrOrStderr(), "Cannot find an authentation token. Do you need to run `nametag auth login`?\n")
-		os.Exit(1)
+// getAuthToken returns the authentication token, or an error if there is
+// none or it has expired.
+func getAuthToken(cmd *cobra.Command) (string, error) {
+	t, err := findAuthToken(cmd)
+	if err != nil {
+		return "", err
 	}
-
-	return authToken, nil
+	if t.Token == "" {
+		return "", errNoAuthToken
+	}
+	if t.Expired() {
+		return "", errAuthTokenExpired
+	}
+	return t.Token, nil
 }
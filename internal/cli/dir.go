@@ -16,9 +16,8 @@ package cli
 
 import (
 	"fmt"
+	"io"
 
-	"github.com/fatih/color"
-	"github.com/rodaine/table"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 
@@ -38,7 +37,7 @@ func newDirCmd() *cobra.Command {
 	return cmd
 }
 func newDirListCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:     "list",
 		Aliases: []string{"ls"},
 		Short:   "list directories",
@@ -55,25 +54,22 @@ func newDirListCmd() *cobra.Command {
 				return fmt.Errorf("%s", resp.Status())
 			}
 
-			headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
-			columnFmt := color.New(color.FgYellow).SprintfFunc()
-
-			tbl := table.New("ID", "Env", "Kind", "Name")
-			tbl.
-				WithWriter(cmd.OutOrStdout()).
-				WithHeaderFormatter(headerFmt).
-				WithFirstColumnFormatter(columnFmt)
-			for _, dir := range lo.FromPtr(resp.JSON200).Directories {
-				tbl.AddRow(dir.ID, dir.Env, dir.Kind, dir.Name)
-			}
-			tbl.Print()
-			return nil
+			directories := lo.FromPtr(resp.JSON200).Directories
+			return printResult(cmd, result{
+				Value:   resp.JSON200,
+				Columns: []string{"ID", "Env", "Kind", "Name"},
+				Rows: lo.Map(directories, func(dir api.Directory, _ int) []string {
+					return []string{dir.ID, dir.Env, string(dir.Kind), dir.Name}
+				}),
+			})
 		},
 	}
+	addOutputFlag(cmd)
+	return cmd
 }
 
 func newDirGetCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "get [dir]",
 		Short: "show an directory",
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -89,46 +85,66 @@ func newDirGetCmd() *cobra.Command {
 				return fmt.Errorf("%s", resp.Status())
 			}
 
-			fmt.Fprintf(cmd.OutOrStdout(), "ID: %s\n", resp.JSON200.ID)
-			fmt.Fprintf(cmd.OutOrStdout(), "Env: %s\n", resp.JSON200.Env)
-			fmt.Fprintf(cmd.OutOrStdout(), "Name: %s\n", resp.JSON200.Name)
-			fmt.Fprintf(cmd.OutOrStdout(), "Kind: %s\n", resp.JSON200.Kind)
+			dir := resp.JSON200
+			return printResult(cmd, result{
+				Value: dir,
+				Columns: []string{"ID", "Env", "Name", "Kind", "LastSyncStartedAt", "LastSyncCompletedAt",
+					"Count", "NeedsReconnect", "SyncRunning"},
+				Rows: [][]string{{
+					dir.ID,
+					dir.Env,
+					dir.Name,
+					string(dir.Kind),
+					formatOptional(dir.LastSyncStartedAt),
+					formatOptional(dir.LastSyncCompletedAt),
+					formatOptional(dir.Count),
+					formatOptional(dir.NeedsReconnect),
+					fmt.Sprint(dir.SyncRunning),
+				}},
+				Object: true,
+				Text: func(w io.Writer) {
+					fmt.Fprintf(w, "ID: %s\n", dir.ID)
+					fmt.Fprintf(w, "Env: %s\n", dir.Env)
+					fmt.Fprintf(w, "Name: %s\n", dir.Name)
+					fmt.Fprintf(w, "Kind: %s\n", dir.Kind)
 
-			printPolicy := func(policy api.RecoveryPolicyRules) {
-				for _, groupPolicy := range policy.Groups {
-					fmt.Fprintf(cmd.OutOrStdout(), "  - Group: %s (%s)\n", groupPolicy.Group.Name, groupPolicy.Group.DirectoryImmutableIdentifier)
-					fmt.Fprintf(cmd.OutOrStdout(), "    Policy: %s\n", groupPolicy.Policy)
-				}
-				fmt.Fprintf(cmd.OutOrStdout(), "  - Default: %s\n", policy.Default)
-			}
+					printPolicy := func(policy api.RecoveryPolicyRules) {
+						for _, groupPolicy := range policy.Groups {
+							fmt.Fprintf(w, "  - Group: %s (%s)\n", groupPolicy.Group.Name, groupPolicy.Group.DirectoryImmutableIdentifier)
+							fmt.Fprintf(w, "    Policy: %s\n", groupPolicy.Policy)
+						}
+						fmt.Fprintf(w, "  - Default: %s\n", policy.Default)
+					}
 
-			if t := resp.JSON200.LastSyncStartedAt; t != nil {
-				fmt.Fprintf(cmd.OutOrStdout(), "LastSyncStartedAt: %s\n", *t)
-			}
-			if t := resp.JSON200.LastSyncCompletedAt; t != nil {
-				fmt.Fprintf(cmd.OutOrStdout(), "LastSyncCompletedAt: %s\n", *t)
-			}
-			if c := resp.JSON200.Count; c != nil && *c > 0 {
-				fmt.Fprintf(cmd.OutOrStdout(), "Count: %d\n", *c)
-			}
-			if v := resp.JSON200.NeedsReconnect; v != nil && *v {
-				fmt.Fprintf(cmd.OutOrStdout(), "NeedsReconnect: true\n")
-			}
-			if v := resp.JSON200.SyncRunning; v {
-				fmt.Fprintf(cmd.OutOrStdout(), "SyncRunning: true\n")
-			}
-			fmt.Fprintf(cmd.OutOrStdout(), "AuthenticatePolicy:\n")
-			printPolicy(resp.JSON200.AuthenticatePolicy)
-			fmt.Fprintf(cmd.OutOrStdout(), "MFAPolicy:\n")
-			printPolicy(resp.JSON200.MfaPolicy)
-			fmt.Fprintf(cmd.OutOrStdout(), "PasswordPolicy:\n")
-			printPolicy(resp.JSON200.PasswordPolicy)
-			fmt.Fprintf(cmd.OutOrStdout(), "UnlockPolicy:\n")
-			printPolicy(resp.JSON200.UnlockPolicy)
-			fmt.Fprintf(cmd.OutOrStdout(), "TemporaryAccessPassPolicy:\n")
-			printPolicy(resp.JSON200.TemporaryAccessPassPolicy)
-
-			return nil
+					if t := dir.LastSyncStartedAt; t != nil {
+						fmt.Fprintf(w, "LastSyncStartedAt: %s\n", *t)
+					}
+					if t := dir.LastSyncCompletedAt; t != nil {
+						fmt.Fprintf(w, "LastSyncCompletedAt: %s\n", *t)
+					}
+					if c := dir.Count; c != nil && *c > 0 {
+						fmt.Fprintf(w, "Count: %d\n", *c)
+					}
+					if v := dir.NeedsReconnect; v != nil && *v {
+						fmt.Fprintf(w, "NeedsReconnect: true\n")
+					}
+					if v := dir.SyncRunning; v {
+						fmt.Fprintf(w, "SyncRunning: true\n")
+					}
+					fmt.Fprintf(w, "AuthenticatePolicy:\n")
+					printPolicy(dir.AuthenticatePolicy)
+					fmt.Fprintf(w, "MFAPolicy:\n")
+					printPolicy(dir.MfaPolicy)
+					fmt.Fprintf(w, "PasswordPolicy:\n")
+					printPolicy(dir.PasswordPolicy)
+					fmt.Fprintf(w, "UnlockPolicy:\n")
+					printPolicy(dir.UnlockPolicy)
+					fmt.Fprintf(w, "TemporaryAccessPassPolicy:\n")
+					printPolicy(dir.TemporaryAccessPassPolicy)
+				},
+			})
 		},
 	}
+	addOutputFlag(cmd)
+	return cmd
 }
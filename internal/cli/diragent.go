@@ -16,7 +16,6 @@ package cli
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"io"
 	"mime/multipart"
@@ -51,6 +50,30 @@ command is equivalent to the one above:
 	OKTA_TOKEN="1234567890" \
 	OKTA_URL="https://example.okta.com" \
     nametag directory agent okta
+The settings of the built-in workers can also be kept in the configuration file as named
+directories, selected with --profile. Flags and environment variables take precedence over
+the file. For example:
+    version: "1"
+    directories:
+      corp-ldap:
+        agentToken: abcd
+        ldap:
+          ldapURL: ldaps://ldap.example.com
+          baseDN: dc=example,dc=com
+      okta-prod:
+        okta:
+          url: https://example.okta.com
+          token: "1234567890"
+    nametag directory agent ldap --profile corp-ldap
+On these commands, --profile selects a directory rather than a Nametag profile; use
+$NAMETAG_PROFILE to select the Nametag profile.
+Tokens, client secrets and passwords, whether given in flags, environment variables or the
+configuration file, can be secret references instead of plaintext values:
+    env:NAME       the value of the environment variable NAME
+    file:PATH      the contents of a file, such as file:/run/secrets/okta-token
+    exec:COMMAND   the output of a command run with your system shell
+References are resolved at startup, and resolved again when a credential is rejected, so
+rotated secrets are picked up without restarting the agent.
 An agent worker can be anything that reads JSON requests from stdin and writes JSON responses 
 to stdout. The command you specify is invoked via your system shell. The environment variable 
 NAMETAG_AGENT_WORKER is set to "true" when the agent is invoked as a worker process. 
@@ -78,14 +101,19 @@ For example:
 				return err
 			}
 
+			server, err := getServer(cmd)
+			if err != nil {
+				return err
+			}
+
 			svc := diragent.Service{
-				Server:    getServer(cmd),
+				Server:    server,
 				AuthToken: agentToken,
 				Command:   command,
 				Env:       env,
 				Stderr:    cmd.ErrOrStderr(),
 			}
-			return svc.Run(cmd.Context())
+			return runAgentService(cmd, &svc)
 		},
 	}
 	addDirectoryHTTPHeaderFlags(cmd)
@@ -168,27 +196,36 @@ func newDirAgentRegisterCmd() *cobra.Command {
 			}
 
 			if jsonOutput {
-				e := json.NewEncoder(cmd.OutOrStdout())
-				e.SetIndent("", "\t")
-				return e.Encode(resp.JSON200)
-			}
-
-			fmt.Fprintf(cmd.OutOrStdout(), "Created a new directory: %s\n", resp.JSON200.ID)
-			fmt.Fprintf(cmd.OutOrStdout(), "\n")
-			fmt.Fprintf(cmd.OutOrStdout(), "You can run an agent for this directory with:\n")
-			fmt.Fprintf(cmd.OutOrStdout(), "\n")
-			fmt.Fprintf(cmd.OutOrStdout(), "  export NAMETAG_AGENT_TOKEN=%q\n",
-				lo.FromPtr(resp.JSON200.AgentToken))
-			fmt.Fprintf(cmd.OutOrStdout(), "  nametag directory agent [provider]\n")
-			fmt.Fprintf(cmd.OutOrStdout(), "\n")
-			fmt.Fprintf(cmd.OutOrStdout(), "See 'nametag directory agent --help' for more options.\n")
-			return nil
+				if err := cmd.Flags().Set("output", outputJSON); err != nil {
+					return err
+				}
+			}
+			dir := resp.JSON200
+			return printResult(cmd, result{
+				Value:   dir,
+				Columns: []string{"ID", "AgentToken"},
+				Rows:    [][]string{{dir.ID, lo.FromPtr(dir.AgentToken)}},
+				Object:  true,
+				Text: func(w io.Writer) {
+					fmt.Fprintf(w, "Created a new directory: %s\n", dir.ID)
+					fmt.Fprintf(w, "\n")
+					fmt.Fprintf(w, "You can run an agent for this directory with:\n")
+					fmt.Fprintf(w, "\n")
+					fmt.Fprintf(w, "  export NAMETAG_AGENT_TOKEN=%q\n",
+						lo.FromPtr(dir.AgentToken))
+					fmt.Fprintf(w, "  nametag directory agent [provider]\n")
+					fmt.Fprintf(w, "\n")
+					fmt.Fprintf(w, "See 'nametag directory agent --help' for more options.\n")
+				},
+			})
 		},
 	}
 	cmd.Flags().StringP("env", "e", "", "The environment to use for the directory")
 	_ = cmd.MarkFlagRequired("env")
 	cmd.Flags().Bool("json", false, "Output in JSON format")
+	_ = cmd.Flags().MarkDeprecated("json", "use --output json instead")
 	cmd.Flags().StringP("logo", "l", "", "The logo for the directory")
+	addOutputFlag(cmd)
 	return cmd
 }
 
@@ -219,22 +256,31 @@ func newDirAgentRegenerateTokenCmd() *cobra.Command {
 			}
 
 			if jsonOutput {
-				e := json.NewEncoder(cmd.OutOrStdout())
-				e.SetIndent("", "\t")
-				return e.Encode(resp.JSON200)
-			}
-
-			fmt.Fprintf(cmd.OutOrStdout(), "You can run an agent for this directory with:\n")
-			fmt.Fprintf(cmd.OutOrStdout(), "\n")
-			fmt.Fprintf(cmd.OutOrStdout(), "  export NAMETAG_AGENT_TOKEN=%q\n", resp.JSON200.AgentToken)
-			fmt.Fprintf(cmd.OutOrStdout(), "  nametag directory agent [provider]\n")
-			fmt.Fprintf(cmd.OutOrStdout(), "\n")
-			fmt.Fprintf(cmd.OutOrStdout(), "See 'nametag directory agent --help' for more options.\n")
-			return nil
+				if err := cmd.Flags().Set("output", outputJSON); err != nil {
+					return err
+				}
+			}
+			token := resp.JSON200
+			return printResult(cmd, result{
+				Value:   token,
+				Columns: []string{"AgentToken"},
+				Rows:    [][]string{{token.AgentToken}},
+				Object:  true,
+				Text: func(w io.Writer) {
+					fmt.Fprintf(w, "You can run an agent for this directory with:\n")
+					fmt.Fprintf(w, "\n")
+					fmt.Fprintf(w, "  export NAMETAG_AGENT_TOKEN=%q\n", token.AgentToken)
+					fmt.Fprintf(w, "  nametag directory agent [provider]\n")
+					fmt.Fprintf(w, "\n")
+					fmt.Fprintf(w, "See 'nametag directory agent --help' for more options.\n")
+				},
+			})
 		},
 	}
 	cmd.Flags().StringP("dir", "d", "", "The ID of the directory you want to reconfigure")
 	_ = cmd.MarkFlagRequired("dir")
 	cmd.Flags().Bool("json", false, "Output in JSON format")
+	_ = cmd.Flags().MarkDeprecated("json", "use --output json instead")
+	addOutputFlag(cmd)
 	return cmd
 }
@@ -15,12 +15,15 @@
 package cli
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/kballard/go-shellquote"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 
 	"github.com/nametaginc/cli/directory/dirad"
+	"github.com/nametaginc/cli/directory/pwgen"
 	"github.com/nametaginc/cli/internal/diragent"
 )
 
@@ -41,6 +44,11 @@ It uses powershell to communicate with the AD server.
 The current user must have permission to run powershell commands with sufficient privileges.
 The ActiveDirectory module must be installed.
 
+On other platforms, use the LDAP directory agent, which talks to domain controllers
+directly over LDAPS:
+
+  nametag dir agent ldap --ldap-url ldaps://dc.example.com --schema-preset activedirectory
+
 When invoked as a subcommand of 'nametag dir agent', the command runs as a worker, receiving
 commands on stdin and sending responses to stdout. For example:
 
@@ -53,28 +61,51 @@ both the worker and the agent roles. For example, the following is equivalent to
 
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			directory, profile, err := readDirectoryConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if profile != "" && directory.AD == nil {
+				return fmt.Errorf("directory %q is not an ad directory", profile)
+			}
+			adConfig := lo.FromPtr(directory.AD)
+
 			// we are not the worker, we are called as a top-level command, so run the agent,
 			// passing the current command line as the command to run.
 			if os.Getenv("NAMETAG_AGENT_WORKER") != "true" {
-				agentToken, err := cmd.Flags().GetString("agent-token")
+				agentToken, err := getAgentToken(cmd, directory)
+				if err != nil {
+					return err
+				}
+
+				server, err := getServer(cmd)
 				if err != nil {
 					return err
 				}
 
 				svc := diragent.Service{
-					Server:    getServer(cmd),
+					Server:    server,
 					AuthToken: agentToken,
 					Command:   shellquote.Join(os.Args...),
 					Stderr:    cmd.ErrOrStderr(),
 				}
-				return svc.Run(cmd.Context())
+				return runAgentService(cmd, &svc)
 			}
 
-			provider := dirad.Provider{}
+			if err := readPasswordGenerationFlags(cmd, &adConfig.PasswordGeneration); err != nil {
+				return err
+			}
+			provider := dirad.Provider{
+				PasswordGeneration: pwgen.Options(adConfig.PasswordGeneration),
+			}
 
 			return diragent.RunWorker(cmd.Context(), &provider)
 		},
 	}
 	cmd.Flags().String("agent-token", os.Getenv("NAMETAG_AGENT_TOKEN"), "Nametag directory agent authentication token ($NAMETAG_AGENT_TOKEN)")
+	cmd.Flags().Int("password-min-length", 0, "minimum length of generated temporary passwords, if longer than the password policy requires (default 12)")
+	cmd.Flags().String("password-exclude-chars", "", "characters never used in generated temporary passwords")
+	cmd.Flags().Bool("password-human-friendly", false, "avoid easily confused characters in generated temporary passwords")
+	addDirectoryProfileFlag(cmd)
 	return cmd
 }
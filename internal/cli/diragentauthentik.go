@@ -20,6 +20,7 @@ import (
 	"strings"
 
 	"github.com/kballard/go-shellquote"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 
 	"github.com/nametaginc/cli/directory/dirauthentik"
@@ -50,44 +51,41 @@ the worker and the agent roles. For example, the following is equivalent to the
   nametag directory agent authentik
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			url, err := cmd.Flags().GetString("authentik-url")
+			directory, profile, err := readDirectoryConfig(cmd)
 			if err != nil {
 				return err
 			}
-			if url == "" {
-				return fmt.Errorf("flag authentik-url or environment variable $AUTHENTIK_URL is required")
+			if profile != "" && directory.Authentik == nil {
+				return fmt.Errorf("directory %q is not an authentik directory", profile)
 			}
+			authentikConfig := lo.FromPtr(directory.Authentik)
 
-			token, err := cmd.Flags().GetString("authentik-token")
-			if err != nil {
-				return err
-			}
-			if token == "" {
-				return fmt.Errorf("flag authentik-token or environment variable $AUTHENTIK_TOKEN is required")
-			}
-			path, err := cmd.Flags().GetString("authentik-users-path")
-			if err != nil {
-				return err
-			}
-			groupsByName, err := cmd.Flags().GetStringSlice("authentik-users-groups-by-name")
-			if err != nil {
-				return err
-			}
-			types, err := cmd.Flags().GetStringSlice("authentik-users-type")
-			if err != nil {
-				return err
+			for name, dst := range map[string]*string{
+				"authentik-url":                        &authentikConfig.URL,
+				"authentik-token":                      &authentikConfig.Token,
+				"authentik-users-path":                 &authentikConfig.UsersPath,
+				"authentik-users-name-attribute":       &authentikConfig.UsersNameAttribute,
+				"authentik-users-birth-date-attribute": &authentikConfig.UsersBirthDateAttribute,
+				"authentik-mfa-reset-flow-uuid":        &authentikConfig.MFAResetFlowUUID,
+			} {
+				if err := overrideString(cmd, name, dst); err != nil {
+					return err
+				}
 			}
-			nameAttribute, err := cmd.Flags().GetString("authentik-users-name-attribute")
-			if err != nil {
-				return err
+			for name, dst := range map[string]*[]string{
+				"authentik-users-groups-by-name": &authentikConfig.UsersGroupsByName,
+				"authentik-users-type":           &authentikConfig.UsersType,
+			} {
+				if err := overrideStringSlice(cmd, name, dst); err != nil {
+					return err
+				}
 			}
-			birthDateAttribute, err := cmd.Flags().GetString("authentik-users-birth-date-attribute")
-			if err != nil {
-				return err
+
+			if authentikConfig.URL == "" {
+				return fmt.Errorf("flag authentik-url or environment variable $AUTHENTIK_URL is required")
 			}
-			mfaResetFlowUUID, err := cmd.Flags().GetString("authentik-mfa-reset-flow-uuid")
-			if err != nil {
-				return err
+			if authentikConfig.Token == "" {
+				return fmt.Errorf("flag authentik-token or environment variable $AUTHENTIK_TOKEN is required")
 			}
 			extraHeaders, err := getDirectoryHTTPHeaders(cmd)
 			if err != nil {
@@ -101,35 +99,41 @@ the worker and the agent roles. For example, the following is equivalent to the
 			// we are not the worker, we are called as a top-level command, so run the agent,
 			// passing the current command line as the command to run.
 			if os.Getenv("NAMETAG_AGENT_WORKER") != "true" {
-				agentToken, err := cmd.Flags().GetString("agent-token")
+				agentToken, err := getAgentToken(cmd, directory)
 				if err != nil {
 					return err
 				}
-				if agentToken == "" {
-					agentToken = os.Getenv("NAMETAG_AGENT_TOKEN")
+
+				server, err := getServer(cmd)
+				if err != nil {
+					return err
 				}
 
 				svc := diragent.Service{
-					Server:    getServer(cmd),
+					Server:    server,
 					AuthToken: agentToken,
 					Command:   shellquote.Join(os.Args...),
 					Env:       env,
 					Stderr:    cmd.ErrOrStderr(),
 				}
-				return svc.Run(cmd.Context())
+				return runAgentService(cmd, &svc)
 			}
 
 			provider := dirauthentik.Provider{
-				URL:                url,
-				Token:              token,
-				Path:               path,
-				GroupsByName:       groupsByName,
-				Types:              types,
-				NameAttribute:      nameAttribute,
-				BirthDateAttribute: birthDateAttribute,
-				MFAResetFlowUUID:   mfaResetFlowUUID,
+				URL:                authentikConfig.URL,
+				Token:              authentikConfig.Token,
+				Path:               authentikConfig.UsersPath,
+				GroupsByName:       authentikConfig.UsersGroupsByName,
+				Types:              authentikConfig.UsersType,
+				NameAttribute:      authentikConfig.UsersNameAttribute,
+				BirthDateAttribute: authentikConfig.UsersBirthDateAttribute,
+				MFAResetFlowUUID:   authentikConfig.MFAResetFlowUUID,
 				ExtraHeaders:       extraHeaders,
 			}
+			provider.RefreshCredentials, err = resolveSecrets(&provider.Token)
+			if err != nil {
+				return err
+			}
 			return diragent.RunWorker(cmd.Context(), &provider)
 		},
 	}
@@ -162,6 +166,7 @@ the worker and the agent roles. For example, the following is equivalent to the
 		os.Getenv("AUTHENTIK_USERS_BIRTH_DATE_ATTRIBUTE"),
 		"Use the Authentik user attributes key to populate account birth date/hash ($AUTHENTIK_USERS_BIRTH_DATE_ATTRIBUTE)",
 	)
+	addDirectoryProfileFlag(cmd)
 	return cmd
 }
 
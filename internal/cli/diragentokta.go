@@ -17,12 +17,15 @@ package cli
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/kballard/go-shellquote"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 
 	"github.com/nametaginc/cli/directory/dirokta"
 	"github.com/nametaginc/cli/internal/diragent"
+	"github.com/nametaginc/cli/internal/pkg/jsonx"
 )
 
 func newDirAgentOktaCmd() *cobra.Command {
@@ -34,7 +37,8 @@ The Okta directory agent performs operations on behalf of Nametag such as listin
 resetting passwords, resetting MFA, and unlocking accounts. Running a directory 
 agent allows you to shield your directory credentials from Nametag or customize the behavior 
 of already-supported directories.
-You must specify an Okta URL and either (1) an Okta API token or (2) an Okta client ID and secret.
+You must specify an Okta URL and either (1) an Okta API token, (2) an Okta client ID and secret,
+or (3) an Okta client ID and a private key (PEM or JWK file) for private_key_jwt authentication.
 When invoked as a subcommand of 'nametag directory agent', the command runs as a worker, receiving
 commands on stdin and sending responses to stdout. For example:
     NAMETAG_AGENT_TOKEN="abcd" nametag directory agent --command "NAMETAG_AGENT_TOKEN="abcd" \
@@ -49,53 +53,106 @@ both the worker and the agent roles. For example, the following is equivalent to
     nametag directory agent okta
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			url, err := cmd.Flags().GetString("okta-url")
+			directory, profile, err := readDirectoryConfig(cmd)
 			if err != nil {
 				return err
 			}
-			if url == "" {
-				return fmt.Errorf("flag url or environment variable $OKTA_URL is required")
+			if profile != "" && directory.Okta == nil {
+				return fmt.Errorf("directory %q is not an okta directory", profile)
 			}
+			oktaConfig := lo.FromPtr(directory.Okta)
 
-			token, err := cmd.Flags().GetString("okta-token")
-			if err != nil {
-				return err
+			for name, dst := range map[string]*string{
+				"okta-url":                  &oktaConfig.URL,
+				"okta-token":                &oktaConfig.Token,
+				"okta-client-id":            &oktaConfig.ClientID,
+				"okta-client-secret":        &oktaConfig.ClientSecret,
+				"okta-private-key":          &oktaConfig.PrivateKeyFile,
+				"okta-key-id":               &oktaConfig.KeyID,
+				"okta-name-attribute":       &oktaConfig.NameAttribute,
+				"okta-name-template":        &oktaConfig.NameTemplate,
+				"okta-birth-date-attribute": &oktaConfig.BirthDateAttribute,
+				"okta-users-search":         &oktaConfig.UsersSearch,
+			} {
+				if err := overrideString(cmd, name, dst); err != nil {
+					return err
+				}
 			}
-			clientID, err := cmd.Flags().GetString("okta-client-id")
-			if err != nil {
-				return err
+			for name, dst := range map[string]*[]string{
+				"okta-scopes":            &oktaConfig.Scopes,
+				"okta-id-attributes":     &oktaConfig.IDAttributes,
+				"okta-users-groups":      &oktaConfig.UsersGroups,
+				"okta-users-status":      &oktaConfig.UsersStatus,
+				"okta-include-group-ids": &oktaConfig.IncludeGroupIDs,
+			} {
+				if err := overrideStringSlice(cmd, name, dst); err != nil {
+					return err
+				}
 			}
-			clientSecret, err := cmd.Flags().GetString("okta-client-secret")
-			if err != nil {
+			if err := overrideBool(cmd, "okta-include-groups", "OKTA_INCLUDE_GROUPS", &oktaConfig.IncludeGroups); err != nil {
 				return err
 			}
+			if cmd.Flags().Changed("okta-rate-limit-share") || oktaConfig.RateLimitShare == 0 {
+				if oktaConfig.RateLimitShare, err = cmd.Flags().GetFloat64("okta-rate-limit-share"); err != nil {
+					return err
+				}
+			}
+			if cmd.Flags().Changed("okta-mfa-bypass-ttl") || oktaConfig.MFABypassTTL == 0 {
+				mfaBypassCodeTTL, err := cmd.Flags().GetDuration("okta-mfa-bypass-ttl")
+				if err != nil {
+					return err
+				}
+				oktaConfig.MFABypassTTL = jsonx.Duration(mfaBypassCodeTTL)
+			}
+
+			url := oktaConfig.URL
+			if url == "" {
+				return fmt.Errorf("flag url or environment variable $OKTA_URL is required")
+			}
+			token := oktaConfig.Token
+			clientID := oktaConfig.ClientID
+			clientSecret := oktaConfig.ClientSecret
+
+			var privateKey string
+			if oktaConfig.PrivateKeyFile != "" {
+				buf, err := os.ReadFile(oktaConfig.PrivateKeyFile) // #nosec G304
+				if err != nil {
+					return fmt.Errorf("cannot read okta private key: %w", err)
+				}
+				privateKey = string(buf)
+			}
+			if oktaConfig.RateLimitShare <= 0 || oktaConfig.RateLimitShare > 1 {
+				return fmt.Errorf("okta-rate-limit-share must be greater than 0 and at most 1")
+			}
 
 			if token != "" {
 				// ok
-			} else if clientID != "" && clientSecret != "" {
+			} else if clientID != "" && (clientSecret != "" || privateKey != "") {
 				// ok
 			} else {
-				return fmt.Errorf("at least one of okta-token or both okta-client-id and okta-client-secret are required")
+				return fmt.Errorf("at least one of okta-token or okta-client-id with okta-client-secret or okta-private-key is required")
 			}
 
 			// we are not the worker, we are called as a top-level command, so run the agent,
 			// passing the current command line as the command to run.
 			if os.Getenv("NAMETAG_AGENT_WORKER") != "true" {
-				agentToken, err := cmd.Flags().GetString("agent-token")
+				agentToken, err := getAgentToken(cmd, directory)
 				if err != nil {
 					return err
 				}
-				if agentToken == "" {
-					agentToken = os.Getenv("NAMETAG_AGENT_TOKEN")
+
+				server, err := getServer(cmd)
+				if err != nil {
+					return err
 				}
 
 				svc := diragent.Service{
-					Server:    getServer(cmd),
+					Server:    server,
 					AuthToken: agentToken,
 					Command:   shellquote.Join(os.Args...),
 					Stderr:    cmd.ErrOrStderr(),
 				}
-				return svc.Run(cmd.Context())
+				return runAgentService(cmd, &svc)
 			}
 
 			provider := dirokta.Provider{
@@ -103,6 +160,29 @@ both the worker and the agent roles. For example, the following is equivalent to
 				Token:        token,
 				ClientID:     clientID,
 				ClientSecret: clientSecret,
+				PrivateKey:   privateKey,
+				KeyID:        oktaConfig.KeyID,
+				Scopes:       oktaConfig.Scopes,
+
+				IDAttributes:       oktaConfig.IDAttributes,
+				NameAttribute:      oktaConfig.NameAttribute,
+				NameTemplate:       oktaConfig.NameTemplate,
+				BirthDateAttribute: oktaConfig.BirthDateAttribute,
+
+				Groups:   oktaConfig.UsersGroups,
+				Statuses: oktaConfig.UsersStatus,
+				Search:   oktaConfig.UsersSearch,
+
+				IncludeGroups:   oktaConfig.IncludeGroups || len(oktaConfig.IncludeGroupIDs) > 0,
+				IncludeGroupIDs: oktaConfig.IncludeGroupIDs,
+
+				RateLimitShare: oktaConfig.RateLimitShare,
+
+				MFABypassCodeTTL: time.Duration(oktaConfig.MFABypassTTL),
+			}
+			provider.RefreshCredentials, err = resolveSecrets(&provider.Token, &provider.ClientSecret)
+			if err != nil {
+				return err
 			}
 			return diragent.RunWorker(cmd.Context(), &provider)
 		},
@@ -112,5 +192,56 @@ both the worker and the agent roles. For example, the following is equivalent to
 	cmd.Flags().String("okta-token", os.Getenv("OKTA_TOKEN"), "Your Okta API key ($OKTA_TOKEN)")
 	cmd.Flags().String("okta-client-id", os.Getenv("OKTA_CLIENT_ID"), "Your Okta Client ID ($OKTA_CLIENT_ID)")
 	cmd.Flags().String("okta-client-secret", os.Getenv("OKTA_CLIENT_SECRET"), "Your Okta Client Secret ($OKTA_CLIENT_SECRET)")
+	cmd.Flags().String("okta-private-key", os.Getenv("OKTA_PRIVATE_KEY"), "Path to a PEM or JWK private key for private_key_jwt authentication ($OKTA_PRIVATE_KEY)")
+	cmd.Flags().String("okta-key-id", os.Getenv("OKTA_KEY_ID"), "The key ID of the Okta private key ($OKTA_KEY_ID)")
+	cmd.Flags().StringSlice("okta-scopes", splitCommaSeparatedEnv(os.Getenv("OKTA_SCOPES")), "OAuth scopes to request when using a client ID (repeat flag or comma-separated, $OKTA_SCOPES)")
+	cmd.Flags().StringSlice(
+		"okta-id-attributes",
+		splitCommaSeparatedEnv(os.Getenv("OKTA_ID_ATTRIBUTES")),
+		"Okta profile attributes that identify a user, default login,email,secondEmail (repeat flag or comma-separated, $OKTA_ID_ATTRIBUTES)",
+	)
+	cmd.Flags().String(
+		"okta-name-attribute",
+		os.Getenv("OKTA_NAME_ATTRIBUTE"),
+		"Use the Okta profile attribute to populate account name ($OKTA_NAME_ATTRIBUTE)",
+	)
+	cmd.Flags().String(
+		"okta-name-template",
+		os.Getenv("OKTA_NAME_TEMPLATE"),
+		"Build the account name from Okta profile attributes, default \"{firstName} {lastName}\" ($OKTA_NAME_TEMPLATE)",
+	)
+	cmd.Flags().String(
+		"okta-birth-date-attribute",
+		os.Getenv("OKTA_BIRTH_DATE_ATTRIBUTE"),
+		"Use the Okta profile attribute to populate account birth date/hash, default birthdate ($OKTA_BIRTH_DATE_ATTRIBUTE)",
+	)
+	cmd.Flags().StringSlice(
+		"okta-users-groups",
+		splitCommaSeparatedEnv(os.Getenv("OKTA_USERS_GROUPS")),
+		"Only sync members of the Okta groups with these IDs (repeat flag or comma-separated, $OKTA_USERS_GROUPS)",
+	)
+	cmd.Flags().StringSlice(
+		"okta-users-status",
+		splitCommaSeparatedEnv(os.Getenv("OKTA_USERS_STATUS")),
+		"Only sync Okta users with these statuses, e.g. ACTIVE,LOCKED_OUT (repeat flag or comma-separated, $OKTA_USERS_STATUS)",
+	)
+	cmd.Flags().String(
+		"okta-users-search",
+		os.Getenv("OKTA_USERS_SEARCH"),
+		"Only sync Okta users matching this search expression, e.g. 'type.id eq \"oty...\"' ($OKTA_USERS_SEARCH)",
+	)
+	cmd.Flags().Bool(
+		"okta-include-groups",
+		os.Getenv("OKTA_INCLUDE_GROUPS") == "true",
+		"Include group membership when listing accounts, read from an index of every group kept up to date from the system log, which requires the okta.logs.read scope ($OKTA_INCLUDE_GROUPS)",
+	)
+	cmd.Flags().StringSlice(
+		"okta-include-group-ids",
+		splitCommaSeparatedEnv(os.Getenv("OKTA_INCLUDE_GROUP_IDS")),
+		"Include membership of only the Okta groups with these IDs, typically those that recovery policies refer to, rather than every group; implies --okta-include-groups (repeat flag or comma-separated, $OKTA_INCLUDE_GROUP_IDS)",
+	)
+	cmd.Flags().Float64("okta-rate-limit-share", 0.5, "Fraction of each Okta rate limit the agent may use, spread over each rate limit window")
+	cmd.Flags().Duration("okta-mfa-bypass-ttl", 24*time.Hour, "How long MFA bypass codes remain valid")
+	addDirectoryProfileFlag(cmd)
 	return cmd
 }
@@ -15,6 +15,7 @@
 package cli
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/kballard/go-shellquote"
@@ -23,6 +24,7 @@ import (
 	"github.com/nametaginc/cli/directory/dirldap"
 	"github.com/nametaginc/cli/internal/config"
 	"github.com/nametaginc/cli/internal/diragent"
+	"github.com/nametaginc/cli/internal/pkg/jsonx"
 )
 
 func newDirAgentLDAPCmd() *cobra.Command {
@@ -36,6 +38,14 @@ resetting passwords and unlocking accounts. Running a directory
 agent allows you to shield your directory credentials from Nametag or customize the behavior
 of already-supported directories.
 
+The --schema-preset flag selects how users and groups are represented in the directory. With
+the activedirectory preset, the agent resets passwords through unicodePwd, honoring
+fine-grained password policies, and unlocks accounts by clearing lockoutTime. Domain
+controllers only accept password changes over ldaps:// or StartTLS.
+
+The agent refuses to bind over an ldap:// URL without StartTLS, because credentials and
+passwords would be sent in cleartext, unless --allow-insecure-bind is set.
+
 All of the command line arguments can be configured in the nametag config file.
 Command line arguments take precedence over config file values.
 
@@ -54,18 +64,27 @@ both the worker and the agent roles. For example, the following is equivalent to
 			// we are not the worker, we are called as a top-level command, so run the agent,
 			// passing the current command line as the command to run.
 			if os.Getenv("NAMETAG_AGENT_WORKER") != "true" {
-				agentToken, err := cmd.Flags().GetString("agent-token")
+				directory, _, err := readDirectoryConfig(cmd)
+				if err != nil {
+					return err
+				}
+				agentToken, err := getAgentToken(cmd, directory)
+				if err != nil {
+					return err
+				}
+
+				server, err := getServer(cmd)
 				if err != nil {
 					return err
 				}
 
 				svc := diragent.Service{
-					Server:    getServer(cmd),
+					Server:    server,
 					AuthToken: agentToken,
 					Command:   shellquote.Join(os.Args...),
 					Stderr:    cmd.ErrOrStderr(),
 				}
-				return svc.Run(cmd.Context())
+				return runAgentService(cmd, &svc)
 			}
 
 			cliConfig, err := config.ReadConfig(cmd)
@@ -73,13 +92,26 @@ both the worker and the agent roles. For example, the following is equivalent to
 				return err
 			}
 
+			// A named directory replaces the top-level LDAPConfig
+			directory, profile, err := readDirectoryConfig(cmd)
+			if err != nil {
+				return err
+			}
+			ldapConfig := cliConfig.LDAPConfig
+			if profile != "" {
+				if directory.LDAP == nil {
+					return fmt.Errorf("directory %q is not an ldap directory", profile)
+				}
+				ldapConfig = *directory.LDAP
+			}
+
 			ldapURL, err := cmd.Flags().GetString("ldap-url")
 			if err != nil {
 				return err
 			}
 
 			if ldapURL != "" {
-				cliConfig.LDAPConfig.LDAPUrl = ldapURL
+				ldapConfig.LDAPUrl = ldapURL
 			}
 
 			bindDn, err := cmd.Flags().GetString("bind-dn")
@@ -88,7 +120,7 @@ both the worker and the agent roles. For example, the following is equivalent to
 			}
 
 			if bindDn != "" {
-				cliConfig.LDAPConfig.BindDN = bindDn
+				ldapConfig.BindDN = bindDn
 			}
 
 			bindPassword, err := cmd.Flags().GetString("bind-password")
@@ -97,7 +129,7 @@ both the worker and the agent roles. For example, the following is equivalent to
 			}
 
 			if bindPassword != "" {
-				cliConfig.LDAPConfig.BindPassword = bindPassword
+				ldapConfig.BindPassword = bindPassword
 			}
 
 			baseDn, err := cmd.Flags().GetString("base-dn")
@@ -106,16 +138,105 @@ both the worker and the agent roles. For example, the following is equivalent to
 			}
 
 			if baseDn != "" {
-				cliConfig.LDAPConfig.BaseDN = baseDn
+				ldapConfig.BaseDN = baseDn
+			}
+
+			if cmd.Flags().Changed("start-tls") || os.Getenv("LDAP_START_TLS") != "" {
+				startTLS, err := cmd.Flags().GetBool("start-tls")
+				if err != nil {
+					return err
+				}
+				ldapConfig.StartTLS = startTLS
+			}
+
+			caFile, err := cmd.Flags().GetString("ca-file")
+			if err != nil {
+				return err
+			}
+
+			if caFile != "" {
+				ldapConfig.CAFile = caFile
+			}
+
+			if err := overrideBool(cmd, "allow-insecure-bind", "LDAP_ALLOW_INSECURE_BIND", &ldapConfig.AllowInsecureBind); err != nil {
+				return err
+			}
+
+			if cmd.Flags().Changed("insecure-skip-verify") {
+				insecureSkipVerify, err := cmd.Flags().GetBool("insecure-skip-verify")
+				if err != nil {
+					return err
+				}
+				ldapConfig.InsecureSkipVerify = insecureSkipVerify
+			}
+
+			clientCert, err := cmd.Flags().GetString("client-cert")
+			if err != nil {
+				return err
+			}
+
+			if clientCert != "" {
+				ldapConfig.ClientCertFile = clientCert
+			}
+
+			clientKey, err := cmd.Flags().GetString("client-key")
+			if err != nil {
+				return err
+			}
+
+			if clientKey != "" {
+				ldapConfig.ClientKeyFile = clientKey
+			}
+
+			if cmd.Flags().Changed("pool-size") {
+				poolSize, err := cmd.Flags().GetInt("pool-size")
+				if err != nil {
+					return err
+				}
+				ldapConfig.PoolSize = poolSize
+			}
+
+			if cmd.Flags().Changed("keepalive-interval") {
+				keepaliveInterval, err := cmd.Flags().GetDuration("keepalive-interval")
+				if err != nil {
+					return err
+				}
+				ldapConfig.KeepaliveInterval = jsonx.Duration(keepaliveInterval)
+			}
+
+			schemaPreset, err := cmd.Flags().GetString("schema-preset")
+			if err != nil {
+				return err
+			}
+
+			if schemaPreset != "" {
+				ldapConfig.Schema.Preset = schemaPreset
+			}
+
+			passwordHash, err := cmd.Flags().GetString("password-hash")
+			if err != nil {
+				return err
+			}
+
+			if passwordHash != "" {
+				ldapConfig.PasswordHash = passwordHash
+			}
+
+			if err := readPasswordGenerationFlags(cmd, &ldapConfig.PasswordGeneration); err != nil {
+				return err
 			}
 
 			// If no pageSize is configured in config, we set a default
-			if cliConfig.LDAPConfig.PageSize == 0 {
-				cliConfig.LDAPConfig.PageSize = 250
+			if ldapConfig.PageSize == 0 {
+				ldapConfig.PageSize = 250
 			}
 
 			provider := dirldap.Provider{
-				Config: &cliConfig.LDAPConfig,
+				Config: &ldapConfig,
+			}
+			provider.RefreshCredentials, err = resolveSecrets(&ldapConfig.BindPassword)
+			if err != nil {
+				return err
 			}
 
 			return diragent.RunWorker(cmd.Context(), &provider)
@@ -126,5 +247,48 @@ both the worker and the agent roles. For example, the following is equivalent to
 	cmd.Flags().String("bind-dn", os.Getenv("BIND_DN"), "ldap bind DN")
 	cmd.Flags().String("bind-password", os.Getenv("BIND_PASSWORD"), "ldap bind password")
 	cmd.Flags().String("base-dn", os.Getenv("BASE_DN"), "ldap base DN")
+	cmd.Flags().Bool("start-tls", os.Getenv("LDAP_START_TLS") == "true", "upgrade ldap:// connections with StartTLS before binding ($LDAP_START_TLS)")
+	cmd.Flags().String("ca-file", os.Getenv("LDAP_CA_FILE"), "PEM file of CA certificates used to verify the ldap server ($LDAP_CA_FILE)")
+	cmd.Flags().Bool("insecure-skip-verify", false, "do not verify the ldap server certificate (insecure)")
+	cmd.Flags().Bool("allow-insecure-bind", os.Getenv("LDAP_ALLOW_INSECURE_BIND") == "true", "allow binding over ldap:// without StartTLS, sending credentials in cleartext (insecure, $LDAP_ALLOW_INSECURE_BIND)")
+	cmd.Flags().String("client-cert", os.Getenv("LDAP_CLIENT_CERT"), "PEM client certificate; binds with SASL EXTERNAL if no bind DN is set ($LDAP_CLIENT_CERT)")
+	cmd.Flags().String("client-key", os.Getenv("LDAP_CLIENT_KEY"), "PEM private key for the client certificate ($LDAP_CLIENT_KEY)")
+	cmd.Flags().Int("pool-size", 1, "number of connections to keep to the ldap server")
+	cmd.Flags().Duration("keepalive-interval", 0, "how often to check idle ldap connections, e.g. 5m (0 disables)")
+	cmd.Flags().String("schema-preset", os.Getenv("LDAP_SCHEMA_PRESET"), "attribute mapping for the directory server: openldap, 389ds, freeipa or activedirectory ($LDAP_SCHEMA_PRESET)")
+	cmd.Flags().String("password-hash", os.Getenv("LDAP_PASSWORD_HASH"), "hash passwords as ssha or argon2, or none if the server hashes them itself, when the server does not support the password modify operation ($LDAP_PASSWORD_HASH)")
+	cmd.Flags().Int("password-min-length", 0, "minimum length of generated temporary passwords, if longer than the password policy requires (default 12)")
+	cmd.Flags().String("password-exclude-chars", "", "characters never used in generated temporary passwords")
+	cmd.Flags().Bool("password-human-friendly", false, "avoid easily confused characters in generated temporary passwords")
+	addDirectoryProfileFlag(cmd)
 	return cmd
 }
+
+// readPasswordGenerationFlags overrides opts with the password generation
+// flags that were set.
+func readPasswordGenerationFlags(cmd *cobra.Command, opts *config.PasswordGeneration) error {
+	if cmd.Flags().Changed("password-min-length") {
+		minLength, err := cmd.Flags().GetInt("password-min-length")
+		if err != nil {
+			return err
+		}
+		opts.MinLength = minLength
+	}
+
+	if cmd.Flags().Changed("password-exclude-chars") {
+		excluded, err := cmd.Flags().GetString("password-exclude-chars")
+		if err != nil {
+			return err
+		}
+		opts.ExcludedCharacters = excluded
+	}
+
+	if cmd.Flags().Changed("password-human-friendly") {
+		humanFriendly, err := cmd.Flags().GetBool("password-human-friendly")
+		if err != nil {
+			return err
+		}
+		opts.HumanFriendly = humanFriendly
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/nametaginc/cli/directory"
+	"github.com/nametaginc/cli/internal/config"
+	"github.com/nametaginc/cli/internal/diragent"
+	"github.com/nametaginc/cli/internal/secret"
+)
+
+// addDirectoryProfileFlag adds the flag that selects one of the named
+// directories in the configuration file. It shadows the global --profile
+// flag, so agent subcommands select their Nametag profile with
+// $NAMETAG_PROFILE.
+func addDirectoryProfileFlag(cmd *cobra.Command) {
+	cmd.Flags().String("profile", os.Getenv("NAMETAG_DIRECTORY_PROFILE"), "name of the directory in the configuration file to use ($NAMETAG_DIRECTORY_PROFILE)")
+}
+
+// readDirectoryConfig returns the directory configuration selected with
+// --profile, or an empty configuration if none is selected. Flags and
+// environment variables take precedence over the values it contains.
+func readDirectoryConfig(cmd *cobra.Command) (*config.DirectoryConfig, string, error) {
+	name, err := cmd.Flags().GetString("profile")
+	if err != nil {
+		return nil, "", err
+	}
+	if name == "" {
+		return &config.DirectoryConfig{}, "", nil
+	}
+
+	cliConfig, err := config.ReadConfig(cmd)
+	if err != nil {
+		return nil, "", err
+	}
+	directory, err := cliConfig.Directory(name)
+	if err != nil {
+		return nil, "", err
+	}
+	return directory, name, nil
+}
+
+// getAgentToken returns the agent token from the flag or environment, or
+// else from the directory configuration.
+func getAgentToken(cmd *cobra.Command, directory *config.DirectoryConfig) (string, error) {
+	agentToken, err := cmd.Flags().GetString("agent-token")
+	if err != nil {
+		return "", err
+	}
+	if agentToken == "" {
+		agentToken = directory.AgentToken
+	}
+	return agentToken, nil
+}
+
+// overrideString sets *dst to the value of a string flag, which defaults to
+// an environment variable, if it is not empty.
+func overrideString(cmd *cobra.Command, name string, dst *string) error {
+	value, err := cmd.Flags().GetString(name)
+	if err != nil {
+		return err
+	}
+	if value != "" {
+		*dst = value
+	}
+	return nil
+}
+
+// overrideStringSlice sets *dst to the value of a string slice flag, which
+// defaults to an environment variable, if it is not empty.
+func overrideStringSlice(cmd *cobra.Command, name string, dst *[]string) error {
+	value, err := cmd.Flags().GetStringSlice(name)
+	if err != nil {
+		return err
+	}
+	if len(value) > 0 {
+		*dst = value
+	}
+	return nil
+}
+
+// overrideBool sets *dst to the value of a bool flag if it was given, or if
+// its environment variable is set.
+func overrideBool(cmd *cobra.Command, name string, envVar string, dst *bool) error {
+	if !cmd.Flags().Changed(name) && (envVar == "" || os.Getenv(envVar) == "") {
+		return nil
+	}
+	value, err := cmd.Flags().GetBool(name)
+	if err != nil {
+		return err
+	}
+	*dst = value
+	return nil
+}
+
+// runAgentService runs svc, resolving its auth token if it is a secret
+// reference, and resolving it again if the server rejects it.
+func runAgentService(cmd *cobra.Command, svc *diragent.Service) error {
+	refresh, err := resolveSecrets(&svc.AuthToken)
+	if err != nil {
+		return err
+	}
+	svc.RefreshToken = refresh
+	return svc.Run(cmd.Context())
+}
+
+// resolveSecrets resolves the secret references among dsts in place. The
+// returned function resolves them again, for providers to call when their
+// credentials are rejected.
+func resolveSecrets(dsts ...*string) (directory.RefreshCredentialsFunc, error) {
+	secrets := &secret.Set{}
+	for _, dst := range dsts {
+		if err := secrets.Add(dst); err != nil {
+			return nil, err
+		}
+	}
+	return secrets.Refresh, nil
+}
@@ -17,10 +17,10 @@ package cli
 import (
 	"fmt"
 
-	"github.com/fatih/color"
-	"github.com/rodaine/table"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+
+	"github.com/nametaginc/cli/internal/api"
 )
 
 func newEnvCmd() *cobra.Command {
@@ -52,21 +52,16 @@ func newEnvListCmd() *cobra.Command {
 				return fmt.Errorf("%s", resp.Status())
 			}
 
-			headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
-			columnFmt := color.New(color.FgYellow).SprintfFunc()
-
-			tbl := table.New("ID", "Name", "Public Name")
-			tbl.
-				WithWriter(cmd.OutOrStdout()).
-				WithHeaderFormatter(headerFmt).
-				WithFirstColumnFormatter(columnFmt)
-			for _, env := range lo.FromPtr(resp.JSON200).Envs {
-				tbl.AddRow(env.ID, env.Name, env.PublicName)
-			}
-			tbl.Print()
-			return nil
+			return printResult(cmd, result{
+				Value:   resp.JSON200,
+				Columns: []string{"ID", "Name", "Public Name"},
+				Rows: lo.Map(lo.FromPtr(resp.JSON200).Envs, func(env api.Env, _ int) []string {
+					return []string{env.ID, env.Name, env.PublicName}
+				}),
+			})
 		},
 	}
+	addOutputFlag(cmd)
 	return cmd
 }
 
@@ -87,12 +82,15 @@ func newEnvGetCmd() *cobra.Command {
 				return fmt.Errorf("%s", resp.Status())
 			}
 
-			fmt.Fprintf(cmd.OutOrStdout(), "ID: %s\n", resp.JSON200.ID)
-			fmt.Fprintf(cmd.OutOrStdout(), "Name: %s\n", resp.JSON200.Name)
-			fmt.Fprintf(cmd.OutOrStdout(), "PublicName: %s\n", resp.JSON200.PublicName)
-			fmt.Fprintf(cmd.OutOrStdout(), "LogoURL: %s\n", resp.JSON200.LogoURL)
-			return nil
+			env := resp.JSON200
+			return printResult(cmd, result{
+				Value:   env,
+				Columns: []string{"ID", "Name", "PublicName", "LogoURL"},
+				Rows:    [][]string{{env.ID, env.Name, env.PublicName, env.LogoURL}},
+				Object:  true,
+			})
 		},
 	}
+	addOutputFlag(cmd)
 	return cmd
 }
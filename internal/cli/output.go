@@ -0,0 +1,256 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/fatih/color"
+	"github.com/ghodss/yaml"
+	"github.com/rodaine/table"
+	"github.com/samber/lo"
+	"github.com/speakeasy-api/jsonpath/pkg/jsonpath"
+	"github.com/spf13/cobra"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Output formats, selected with --output.
+const (
+	outputTable    = "table"
+	outputJSON     = "json"
+	outputYAML     = "yaml"
+	outputCSV      = "csv"
+	outputJSONPath = "jsonpath"
+	outputTemplate = "template"
+)
+
+// outputFormat is a parsed --output flag. Arg is the expression of the
+// jsonpath and template formats.
+type outputFormat struct {
+	Name string
+	Arg  string
+}
+
+// parseOutputFormat parses the value of the --output flag.
+func parseOutputFormat(s string) (outputFormat, error) {
+	name, arg, hasArg := strings.Cut(s, "=")
+	switch name {
+	case outputTable, outputJSON, outputYAML, outputCSV:
+		if hasArg {
+			return outputFormat{}, fmt.Errorf("output format %q does not take an argument", name)
+		}
+	case outputJSONPath, outputTemplate:
+		if arg == "" {
+			return outputFormat{}, fmt.Errorf("output format %q requires an argument, for example --output %s=...", name, name)
+		}
+	default:
+		return outputFormat{}, fmt.Errorf("unknown output format %q, expected table, json, yaml, csv, jsonpath=EXPR or template=TEMPLATE", s)
+	}
+	return outputFormat{Name: name, Arg: arg}, nil
+}
+
+// addOutputFlag adds the --output flag to cmd, which prints its result with
+// printResult. $NAMETAG_OUTPUT only applies to commands with the flag.
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", lo.CoalesceOrEmpty(os.Getenv("NAMETAG_OUTPUT"), outputTable), "Output `format`: table, json, yaml, csv, jsonpath=EXPR or template=TEMPLATE ($NAMETAG_OUTPUT)")
+}
+
+// getOutputFormat returns the output format selected with --output, or the
+// table format if cmd has no --output flag.
+func getOutputFormat(cmd *cobra.Command) (outputFormat, error) {
+	flag := cmd.Flags().Lookup("output")
+	if flag == nil || flag.Value.String() == "" {
+		return outputFormat{Name: outputTable}, nil
+	}
+	return parseOutputFormat(flag.Value.String())
+}
+
+// result is the output of a command, which printResult renders in the
+// format selected with --output.
+type result struct {
+	// Value is rendered by the json, yaml, jsonpath and template formats.
+	// Field names are those of its JSON encoding.
+	Value any
+
+	// Columns and Rows are rendered by the table and csv formats.
+	Columns []string
+	Rows    [][]string
+
+	// Object is true if the result is a single object rather than a list.
+	// The table format writes the columns of its only row as "Column: value"
+	// lines.
+	Object bool
+
+	// Text, if set, writes the table format instead of Columns and Rows, for
+	// results that do not fit in a table.
+	Text func(w io.Writer)
+}
+
+// printResult writes r to the standard output of cmd.
+func printResult(cmd *cobra.Command, r result) error {
+	format, err := getOutputFormat(cmd)
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+
+	switch format.Name {
+	case outputJSON:
+		e := json.NewEncoder(out)
+		e.SetIndent("", "\t")
+		return e.Encode(r.Value)
+
+	case outputYAML:
+		buf, err := yaml.Marshal(r.Value)
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(buf)
+		return err
+
+	case outputCSV:
+		w := csv.NewWriter(out)
+		if err := w.Write(r.Columns); err != nil {
+			return err
+		}
+		if err := w.WriteAll(r.Rows); err != nil {
+			return err
+		}
+		return w.Error()
+
+	case outputJSONPath:
+		return printJSONPath(out, format.Arg, r.Value)
+
+	case outputTemplate:
+		return printTemplate(out, format.Arg, r.Value)
+
+	default:
+		switch {
+		case r.Text != nil:
+			r.Text(out)
+		case r.Object:
+			for _, row := range r.Rows {
+				for i, column := range r.Columns {
+					fmt.Fprintf(out, "%s: %s\n", column, row[i])
+				}
+			}
+		default:
+			headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+			columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+			tbl := table.New(lo.ToAnySlice(r.Columns)...)
+			tbl.
+				WithWriter(out).
+				WithHeaderFormatter(headerFmt).
+				WithFirstColumnFormatter(columnFmt)
+			for _, row := range r.Rows {
+				tbl.AddRow(lo.ToAnySlice(row)...)
+			}
+			tbl.Print()
+		}
+		return nil
+	}
+}
+
+// printJSONPath writes the parts of value selected by the JSONPath (RFC 9535)
+// expression expr, one per line. Strings, numbers and booleans are written
+// as is, and objects and arrays as JSON. For familiarity with kubectl, the
+// expression may be wrapped in braces, and the leading $ may be left out.
+func printJSONPath(out io.Writer, expr string, value any) error {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "{") && strings.HasSuffix(expr, "}") {
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+	if !strings.HasPrefix(expr, "$") {
+		expr = "$" + expr
+	}
+	path, err := jsonpath.NewPath(expr)
+	if err != nil {
+		return fmt.Errorf("invalid jsonpath expression: %w", err)
+	}
+
+	// JSON is YAML, so the value is converted to a YAML node for the query.
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var root yamlv3.Node
+	if err := yamlv3.Unmarshal(buf, &root); err != nil {
+		return err
+	}
+
+	for _, node := range path.Query(&root) {
+		if node.Kind == yamlv3.ScalarNode {
+			fmt.Fprintln(out, node.Value)
+			continue
+		}
+		var v any
+		if err := node.Decode(&v); err != nil {
+			return err
+		}
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, string(buf))
+	}
+	return nil
+}
+
+// printTemplate executes the Go template text with value, converted to plain
+// maps and slices so that fields have the names of the JSON encoding.
+func printTemplate(out io.Writer, text string, value any) error {
+	tmpl, err := template.New("output").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var data any
+	d := json.NewDecoder(bytes.NewReader(buf))
+	d.UseNumber()
+	if err := d.Decode(&data); err != nil {
+		return err
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return err
+	}
+	if rendered.Len() > 0 && !bytes.HasSuffix(rendered.Bytes(), []byte("\n")) {
+		rendered.WriteByte('\n')
+	}
+	_, err = out.Write(rendered.Bytes())
+	return err
+}
+
+// formatOptional formats *v for a table or CSV cell, or returns an empty
+// string if v is nil.
+func formatOptional[T any](v *T) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(*v)
+}
@@ -0,0 +1,200 @@
+// Copyright 2024 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestParseOutputFormat(t *testing.T) {
+	for _, test := range []struct {
+		value   string
+		want    outputFormat
+		wantErr string
+	}{
+		{value: "table", want: outputFormat{Name: outputTable}},
+		{value: "json", want: outputFormat{Name: outputJSON}},
+		{value: "yaml", want: outputFormat{Name: outputYAML}},
+		{value: "csv", want: outputFormat{Name: outputCSV}},
+		{value: "jsonpath={.items[*].id}", want: outputFormat{Name: outputJSONPath, Arg: "{.items[*].id}"}},
+		{value: "template={{.id}}={{.name}}", want: outputFormat{Name: outputTemplate, Arg: "{{.id}}={{.name}}"}},
+		{value: "json=x", wantErr: "does not take an argument"},
+		{value: "table=", wantErr: "does not take an argument"},
+		{value: "jsonpath", wantErr: "requires an argument"},
+		{value: "template=", wantErr: "requires an argument"},
+		{value: "JSON", wantErr: "unknown output format"},
+		{value: "xml", wantErr: "unknown output format"},
+	} {
+		got, err := parseOutputFormat(test.value)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%q: got error %v, want %q", test.value, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.value, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: got %+v, want %+v", test.value, got, test.want)
+		}
+	}
+}
+
+type testOutputItem struct {
+	ID      string   `json:"id"`
+	Count   int      `json:"count"`
+	Enabled bool     `json:"enabled"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+var testOutputValue = map[string]any{
+	"items": []testOutputItem{
+		{ID: "a", Count: 1, Enabled: true, Tags: []string{"x", "y"}},
+		{ID: "b", Count: 12345678901},
+	},
+}
+
+func TestPrintJSONPath(t *testing.T) {
+	for _, test := range []struct {
+		expr    string
+		want    string
+		wantErr string
+	}{
+		{expr: "$.items[*].id", want: "a\nb\n"},
+		{expr: ".items[*].id", want: "a\nb\n"},
+		{expr: "{.items[*].id}", want: "a\nb\n"},
+		{expr: " { .items[1].count } ", want: "12345678901\n"},
+		{expr: "{.items[0].enabled}", want: "true\n"},
+		{expr: "{.items[0].tags}", want: `["x","y"]` + "\n"},
+		{expr: "{.items[1]}", want: `{"count":12345678901,"enabled":false,"id":"b"}` + "\n"},
+		{expr: "{.items[?@.enabled == true].id}", want: "a\n"},
+		{expr: "{.missing}", want: ""},
+		{expr: "{.items[}", wantErr: "invalid jsonpath expression"},
+	} {
+		var out bytes.Buffer
+		err := printJSONPath(&out, test.expr, testOutputValue)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%q: got error %v, want %q", test.expr, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.expr, err)
+			continue
+		}
+		if got := out.String(); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.expr, got, test.want)
+		}
+	}
+}
+
+func TestPrintTemplate(t *testing.T) {
+	for _, test := range []struct {
+		text    string
+		want    string
+		wantErr string
+	}{
+		{text: "{{range .items}}{{.id}} {{.count}}\n{{end}}", want: "a 1\nb 12345678901\n"},
+		{text: "{{(index .items 0).enabled}}", want: "true\n"},
+		{text: "{{range (index .items 0).tags}}{{.}},{{end}}", want: "x,y,\n"},
+		{text: "{{(index .items 1).missing}}", want: "<no value>\n"},
+		{text: "{{len .items}}\n", want: "2\n"},
+		{text: "", want: ""},
+		{text: "{{.items", wantErr: "invalid template"},
+		{text: "{{index .items 5}}", wantErr: "out of range"},
+	} {
+		var out bytes.Buffer
+		err := printTemplate(&out, test.text, testOutputValue)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%q: got error %v, want %q", test.text, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.text, err)
+			continue
+		}
+		if got := out.String(); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestPrintResult(t *testing.T) {
+	r := result{
+		Value:   testOutputValue,
+		Columns: []string{"ID", "COUNT"},
+		Rows:    [][]string{{"a", "1"}, {"b, c", "2"}},
+	}
+	for _, test := range []struct {
+		output string
+		want   string
+	}{
+		{output: "csv", want: "ID,COUNT\na,1\n\"b, c\",2\n"},
+		{output: "jsonpath={.items[0].id}", want: "a\n"},
+		{output: "template={{(index .items 1).id}}", want: "b\n"},
+		{output: "yaml", want: "items:\n- count: 1\n  enabled: true\n  id: a\n  tags:\n  - x\n  - \"y\"\n- count: 12345678901\n  enabled: false\n  id: b\n"},
+	} {
+		var out bytes.Buffer
+		cmd := &cobra.Command{}
+		cmd.Flags().String("output", test.output, "")
+		cmd.SetOut(&out)
+		if err := printResult(cmd, r); err != nil {
+			t.Errorf("%s: %v", test.output, err)
+			continue
+		}
+		if got := out.String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.output, got, test.want)
+		}
+	}
+}
+
+func TestOutputEnv(t *testing.T) {
+	t.Setenv("NAMETAG_OUTPUT", "xml")
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	for _, test := range []struct {
+		args    []string
+		wantErr string
+	}{
+		{args: []string{"auth", "profiles", "list"}, wantErr: "unknown output format"},
+		{args: []string{"auth", "profiles", "list", "--output", "json"}},
+		// commands that do not print results ignore $NAMETAG_OUTPUT
+		{args: []string{"auth", "logout"}},
+	} {
+		cmd := New()
+		cmd.SetOut(io.Discard)
+		cmd.SetArgs(append(test.args, "--config", path))
+		err := cmd.Execute()
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%v: got error %v, want %q", test.args, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", test.args, err)
+		}
+	}
+}
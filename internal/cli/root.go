@@ -19,8 +19,10 @@ import (
 	_ "embed"
 	"io"
 	"log"
+	"os"
 	"strings"
 
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
 	"github.com/nametaginc/cli/internal/config"
@@ -47,6 +49,16 @@ func New() *cobra.Command {
 			config.ClearCachedConfig() // for testing
 
 			Log.SetOutput(cmd.OutOrStdout())
+
+			// only commands that print results have --output
+			if _, err := getOutputFormat(cmd); err != nil {
+				return err
+			}
+			noColor, err := cmd.Flags().GetBool("no-color")
+			if err != nil {
+				return err
+			}
+			color.NoColor = noColor || !isTerminal(cmd.OutOrStdout())
 			return nil
 		},
 	}
@@ -54,8 +66,10 @@ func New() *cobra.Command {
 	cmd.SetUsageTemplate(usageTemplate)
 	cmd.SetHelpTemplate(helpTemplate)
 
-	cmd.PersistentFlags().StringP("auth-token", "t", "", "Nametag API authentication token")
+	cmd.PersistentFlags().StringP("auth-token", "t", "", "Nametag API authentication token, or a secret reference such as env:NAME, file:PATH or exec:COMMAND")
 	cmd.PersistentFlags().StringP("config", "c", "", "Path to Nametag CLI configuration file")
+	cmd.PersistentFlags().String("profile", os.Getenv("NAMETAG_PROFILE"), "Name of the profile in the configuration file to use, default the current profile ($NAMETAG_PROFILE)")
+	cmd.PersistentFlags().Bool("no-color", os.Getenv("NO_COLOR") != "", "Disable colored output, which is also disabled when the output is not a terminal ($NO_COLOR)")
 
 	cmd.AddCommand(newAuthCmd())
 	cmd.AddCommand(newDirCmd())
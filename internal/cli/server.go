@@ -15,6 +15,7 @@
 package cli
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
@@ -24,15 +25,42 @@ import (
 
 const defaultServer = "https://nametag.co"
 
-func getServer(cmd *cobra.Command) string {
+// getServer returns the Nametag server from $NAMETAG_SERVER, or else the
+// selected profile, or else the default server.
+func getServer(cmd *cobra.Command) (string, error) {
 	if s := os.Getenv("NAMETAG_SERVER"); s != "" {
-		return s
+		return s, nil
 	}
 
-	config, err := config.ReadConfig(cmd)
-	if err == nil && config.Server != "" {
-		return config.Server
+	_, profile, err := getProfile(cmd)
+	if err != nil {
+		return "", err
 	}
+	if profile.Server != "" {
+		return profile.Server, nil
+	}
+	return defaultServer, nil
+}
 
-	return defaultServer
+// getProfile returns the name of the profile selected with --profile, or
+// else the current profile, and the profile. It is an error to select a
+// profile that is not defined in the configuration file, except for the
+// default profile, which is empty until you log in.
+func getProfile(cmd *cobra.Command) (string, *config.Profile, error) {
+	cliConfig, err := config.ReadConfig(cmd)
+	if err != nil {
+		return "", nil, err
+	}
+	name, err := cliConfig.ProfileName(cmd)
+	if err != nil {
+		return "", nil, err
+	}
+	profile := cliConfig.Profile(name)
+	if profile == nil {
+		if name != config.DefaultProfile {
+			return "", nil, fmt.Errorf("profile %q is not defined. Run `nametag auth login --profile %s` to create it", name, name)
+		}
+		profile = &config.Profile{}
+	}
+	return name, profile, nil
 }
@@ -0,0 +1,83 @@
+// Copyright 2024 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// runGetServer runs getServer in a subcommand of the root command with the
+// given configuration file.
+func runGetServer(t *testing.T, configBuf string, args ...string) (string, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(configBuf), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var server string
+	root := New()
+	root.AddCommand(&cobra.Command{
+		Use: "test",
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			server, err = getServer(cmd)
+			return err
+		},
+	})
+	root.SetArgs(append([]string{"test", "--config", path}, args...))
+	err := root.Execute()
+	return server, err
+}
+
+func TestGetServer(t *testing.T) {
+	const configBuf = "version: \"1\"\nserver: https://example.com\ntokenStore: file\nprofiles:\n  staging:\n    server: https://staging.example.com\n"
+	for _, test := range []struct {
+		name    string
+		config  string
+		env     string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{name: "default profile", config: configBuf, want: "https://example.com"},
+		{name: "profile", config: configBuf, args: []string{"--profile", "staging"}, want: "https://staging.example.com"},
+		{name: "env", config: configBuf, env: "https://env.example.com", want: "https://env.example.com"},
+		{name: "undefined profile", config: configBuf, args: []string{"--profile", "prod"}, wantErr: `profile "prod" is not defined`},
+		{name: "no server", config: "version: \"1\"\n", want: defaultServer},
+		{name: "invalid config", config: "version: \"2\"\n", wantErr: "unsupported configuration file version"},
+	} {
+		t.Setenv("NAMETAG_SERVER", test.env)
+		t.Setenv("NAMETAG_TOKEN_STORE_PASSPHRASE", "")
+		got, err := runGetServer(t, test.config, test.args...)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got error %v, want %q", test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// spinnerFrames are drawn in turn by a spinner.
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// spinner shows that a long operation is in progress, with the time it has
+// taken so far. When out is not a terminal, the message is written once
+// instead.
+type spinner struct {
+	out     io.Writer
+	message string
+	start   time.Time
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newSpinner(out io.Writer, message string) *spinner {
+	s := &spinner{
+		out:     out,
+		message: message,
+		start:   time.Now(),
+		done:    make(chan struct{}),
+	}
+	if !isTerminal(out) {
+		fmt.Fprintf(out, "%s...\n", message)
+		return s
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for frame := 0; ; frame++ {
+			fmt.Fprintf(s.out, "\r%c %s... %s (press Ctrl-C to cancel)",
+				spinnerFrames[frame%len(spinnerFrames)], s.message, time.Since(s.start).Round(time.Second))
+			select {
+			case <-s.done:
+				fmt.Fprintf(s.out, "\r\033[K")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s
+}
+
+// Stop removes the spinner.
+func (s *spinner) Stop() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// isTerminal returns true if w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd())) //nolint:gosec // standard Go pattern for terminal fd conversion
+}
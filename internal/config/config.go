@@ -16,36 +16,266 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
+
+	"github.com/nametaginc/cli/internal/pkg/jsonx"
+	"github.com/nametaginc/cli/internal/tokenstore"
 )
 
 // Config represents the format of the configuration file that
-// contains the authentication token and other settings.
+// contains the authentication token and other settings. ghodss/yaml reads
+// and writes it through encoding/json, so fields are named by json tags.
+//
+// Tokens, client secrets and passwords may be given as secret references,
+// such as env:NAME, file:PATH or exec:COMMAND, which are resolved when they
+// are used. See package secret.
 type Config struct {
-	Version    string     `yaml:"version"`
-	Server     string     `yaml:",omitempty"`
-	Token      string     `yaml:"token"`
-	LDAPConfig LDAPConfig `yaml:"LDAPConfig"`
+	Version    string     `json:"version,omitempty"`
+	Server     string     `json:"server,omitempty"`
+	Token      string     `json:"token,omitempty"`
+	LDAPConfig LDAPConfig `json:"LDAPConfig"`
+
+	TokenStore string `json:"tokenStore,omitempty"`
+
+	// Profiles are named Nametag servers and tokens, selected with
+	// --profile. Server, Token and TokenStore above make up the default
+	// profile. In memory, ReadConfig moves them into Profiles under the
+	// name DefaultProfile, and WriteConfig moves them back.
+	Profiles map[string]*Profile `json:"profiles,omitempty"`
+
+	// CurrentProfile is the profile used when none is selected with
+	// --profile. If it is empty, the default profile is used.
+	CurrentProfile string `json:"currentProfile,omitempty"`
+
+	// Directories are named directory agent configurations, selected with
+	// the --profile flag of the agent subcommands. When no
+	// directory is selected, the LDAP agent uses LDAPConfig.
+	Directories map[string]DirectoryConfig `json:"directories,omitempty"`
+
+	// path is the file the configuration was read from. The file token
+	// store is kept next to it.
+	path string
+}
+
+// DefaultProfile is the name of the profile stored in the top-level
+// Server, Token and TokenStore of the configuration file.
+const DefaultProfile = "default"
+
+// Profile is a Nametag server and the token used to authenticate to it.
+type Profile struct {
+	Server string `json:"server,omitempty"`
+	Token  string `json:"token,omitempty"`
+
+	// TokenStore is where Token is kept if not in the configuration file:
+	// "keyring" or "file".
+	TokenStore string `json:"tokenStore,omitempty"`
+}
+
+// DirectoryConfig is the configuration of one directory agent. Exactly one
+// of the provider sections should be set.
+type DirectoryConfig struct {
+	// AgentToken is the Nametag directory agent authentication token.
+	AgentToken string `json:"agentToken,omitempty"`
+
+	LDAP      *LDAPConfig      `json:"ldap,omitempty"`
+	Okta      *OktaConfig      `json:"okta,omitempty"`
+	Authentik *AuthentikConfig `json:"authentik,omitempty"`
+	AD        *ADConfig        `json:"ad,omitempty"`
+}
+
+// OktaConfig represents the settings of the Okta agent. See dirokta.Provider
+// for the meaning of each setting.
+type OktaConfig struct {
+	URL          string `json:"url,omitempty"`
+	Token        string `json:"token,omitempty"`
+	ClientID     string `json:"clientID,omitempty"`
+	ClientSecret string `json:"clientSecret,omitempty"`
+
+	// PrivateKeyFile is the path of a PEM or JWK private key for
+	// private_key_jwt authentication.
+	PrivateKeyFile string   `json:"privateKeyFile,omitempty"`
+	KeyID          string   `json:"keyID,omitempty"`
+	Scopes         []string `json:"scopes,omitempty"`
+
+	IDAttributes       []string `json:"idAttributes,omitempty"`
+	NameAttribute      string   `json:"nameAttribute,omitempty"`
+	NameTemplate       string   `json:"nameTemplate,omitempty"`
+	BirthDateAttribute string   `json:"birthDateAttribute,omitempty"`
+
+	UsersGroups []string `json:"usersGroups,omitempty"`
+	UsersStatus []string `json:"usersStatus,omitempty"`
+	UsersSearch string   `json:"usersSearch,omitempty"`
+
+	IncludeGroups   bool     `json:"includeGroups,omitempty"`
+	IncludeGroupIDs []string `json:"includeGroupIDs,omitempty"`
+
+	RateLimitShare float64        `json:"rateLimitShare,omitempty"`
+	MFABypassTTL   jsonx.Duration `json:"mfaBypassTTL,omitempty"`
+}
+
+// AuthentikConfig represents the settings of the Authentik agent. See
+// dirauthentik.Provider for the meaning of each setting.
+type AuthentikConfig struct {
+	URL              string `json:"url,omitempty"`
+	Token            string `json:"token,omitempty"`
+	MFAResetFlowUUID string `json:"mfaResetFlowUUID,omitempty"`
+
+	UsersPath               string   `json:"usersPath,omitempty"`
+	UsersGroupsByName       []string `json:"usersGroupsByName,omitempty"`
+	UsersType               []string `json:"usersType,omitempty"`
+	UsersNameAttribute      string   `json:"usersNameAttribute,omitempty"`
+	UsersBirthDateAttribute string   `json:"usersBirthDateAttribute,omitempty"`
+}
+
+// ADConfig represents the settings of the Active Directory agent.
+type ADConfig struct {
+	// PasswordGeneration configures how temporary passwords are generated,
+	// on top of the domain's password policy.
+	PasswordGeneration PasswordGeneration `json:"passwordGeneration,omitempty"`
+}
+
+// PasswordGeneration configures how temporary passwords are generated. It
+// mirrors pwgen.Options, which providers convert it to.
+type PasswordGeneration struct {
+	// MinLength is the floor length of generated passwords. If zero, the
+	// default of 12 characters is used.
+	MinLength int `json:"minLength,omitempty"`
+
+	// ExcludedCharacters are never used in generated passwords.
+	ExcludedCharacters string `json:"excludedCharacters,omitempty"`
+
+	// HumanFriendly avoids characters that are easily confused when read,
+	// such as 0 and O, and uses a small set of symbols.
+	HumanFriendly bool `json:"humanFriendly,omitempty"`
 }
 
 // LDAPConfig represents the format of settings related to the LDAP agent functionality
 type LDAPConfig struct {
-	LDAPUrl                 string `yaml:"ldapURL"`
-	BaseDN                  string `yaml:"baseDN"`
-	BindDN                  string `yaml:"bindDN"`
-	BindPassword            string `yaml:"bindPassword"`
-	PageSize                uint32 `yaml:"pageSize"`
-	DefaultPasswordPolicyDN string `yaml:"defaultPasswordPolicyDN"`
+	LDAPUrl                 string `json:"ldapURL,omitempty"`
+	BaseDN                  string `json:"baseDN,omitempty"`
+	BindDN                  string `json:"bindDN,omitempty"`
+	BindPassword            string `json:"bindPassword,omitempty"`
+	PageSize                uint32 `json:"pageSize,omitempty"`
+	DefaultPasswordPolicyDN string `json:"defaultPasswordPolicyDN,omitempty"`
+
+	// PasswordHash is how passwords are hashed before being written to
+	// userPassword when the server does not support the password modify
+	// extended operation: "ssha" or "argon2", or "none" to write the
+	// password as is, for servers that hash it themselves. It is required
+	// when the server does not support the operation.
+	PasswordHash string `json:"passwordHash,omitempty"`
+
+	// PasswordGeneration configures how temporary passwords are generated,
+	// on top of the directory's password policy.
+	PasswordGeneration PasswordGeneration `json:"passwordGeneration,omitempty"`
+
+	// StartTLS upgrades ldap:// connections to TLS before binding.
+	StartTLS bool `json:"startTLS,omitempty"`
+	// AllowInsecureBind allows binding over an ldap:// connection without
+	// StartTLS, which sends credentials and passwords in cleartext.
+	AllowInsecureBind bool `json:"allowInsecureBind,omitempty"`
+	// CAFile is a PEM file of CA certificates to trust instead of the system roots.
+	CAFile string `json:"caFile,omitempty"`
+	// InsecureSkipVerify disables verification of the server certificate.
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+	// ClientCertFile and ClientKeyFile are a PEM client certificate and key
+	// presented to the server. Without a BindDN, the agent binds with SASL
+	// EXTERNAL using this certificate.
+	ClientCertFile string `json:"clientCertFile,omitempty"`
+	ClientKeyFile  string `json:"clientKeyFile,omitempty"`
+
+	// PoolSize is the number of connections to keep to the server.
+	PoolSize int `json:"poolSize,omitempty"`
+	// KeepaliveInterval is how often idle connections are checked by reading
+	// the root DSE. Zero disables the check.
+	KeepaliveInterval jsonx.Duration `json:"keepaliveInterval,omitempty"`
+
+	// Schema maps directory attributes to account fields.
+	Schema LDAPSchema `json:"schema,omitempty"`
+}
+
+// LDAPSchema describes how users and groups are represented in an LDAP
+// directory. Preset selects a built-in mapping (openldap, 389ds, freeipa or
+// activedirectory, default openldap); the other fields override it.
+type LDAPSchema struct {
+	Preset string `json:"preset,omitempty"`
+
+	// UserFilter and GroupFilter select user and group entries, e.g.
+	// "(objectClass=inetOrgPerson)".
+	UserFilter  string `json:"userFilter,omitempty"`
+	GroupFilter string `json:"groupFilter,omitempty"`
+
+	// ImmutableIDAttribute holds an identifier that never changes, e.g.
+	// entryUUID. ImmutableIDType is "string", or "guid" for binary GUIDs
+	// such as objectGUID.
+	ImmutableIDAttribute string `json:"immutableIDAttribute,omitempty"`
+	ImmutableIDType      string `json:"immutableIDType,omitempty"`
+
+	// IDAttributes hold identifiers such as usernames and email addresses,
+	// including aliases such as mailAlternateAddress. Accounts can be looked
+	// up by any of them.
+	IDAttributes []string `json:"idAttributes,omitempty"`
+
+	// NameAttribute holds the name of a user, and GroupNameAttribute the
+	// name of a group, which group name prefixes are matched against.
+	NameAttribute      string `json:"nameAttribute,omitempty"`
+	GroupNameAttribute string `json:"groupNameAttribute,omitempty"`
+	MemberOfAttribute  string `json:"memberOfAttribute,omitempty"`
+
+	// ModifyTimestampAttribute holds the time an entry was last changed, in
+	// the GeneralizedTime layout TimestampFormat (a Go time layout).
+	ModifyTimestampAttribute string `json:"modifyTimestampAttribute,omitempty"`
+	TimestampFormat          string `json:"timestampFormat,omitempty"`
+
+	// PasswordResetAttribute is set to TRUE after a temporary password is
+	// assigned, so that the user must change it at next login, e.g. pwdReset
+	// for the OpenLDAP ppolicy overlay. Servers that expire passwords set by
+	// an administrator on their own do not need it.
+	PasswordResetAttribute string `json:"passwordResetAttribute,omitempty"`
+
+	// LockAttribute is set when an account is locked out. Unlocking deletes
+	// it, or replaces it with UnlockValue if that is set.
+	LockAttribute string `json:"lockAttribute,omitempty"`
+	UnlockValue   string `json:"unlockValue,omitempty"`
+
+	// GroupMembership is how the groups of a user are found: "memberOf"
+	// (the default) reads MemberOfAttribute from the user, and "member"
+	// searches for groups whose MemberAttribute (default member, or e.g.
+	// uniqueMember) contains the user.
+	GroupMembership string `json:"groupMembership,omitempty"`
+	MemberAttribute string `json:"memberAttribute,omitempty"`
+
+	// NestedGroups includes the groups that a user's groups belong to,
+	// transitively.
+	NestedGroups bool `json:"nestedGroups,omitempty"`
+
+	// DynamicGroupFilter selects dynamic groups, e.g.
+	// "(objectClass=groupOfURLs)", whose members are the entries matching
+	// the LDAP URLs in MemberURLAttribute (default memberURL). Dynamic groups
+	// are not resolved if it is empty.
+	DynamicGroupFilter string `json:"dynamicGroupFilter,omitempty"`
+	MemberURLAttribute string `json:"memberURLAttribute,omitempty"`
 }
 
 var cachedConfig *Config
 
-// ReadConfig returns the config from the file system
+// Directory returns the directory configuration with the given name.
+func (c *Config) Directory(name string) (*DirectoryConfig, error) {
+	directory, ok := c.Directories[name]
+	if !ok {
+		return nil, fmt.Errorf("directory %q is not defined in the configuration file", name)
+	}
+	return &directory, nil
+}
+
+// ReadConfig returns the config from the file system. Tokens kept in a
+// token store are not read; see ProfileToken.
 func ReadConfig(cmd *cobra.Command) (*Config, error) {
 	if cachedConfig != nil {
 		return cachedConfig, nil
@@ -56,7 +286,7 @@ func ReadConfig(cmd *cobra.Command) (*Config, error) {
 	}
 	configBuf, err := os.ReadFile(path) //nolint:gosec  // no file inclusion vulnerability; this is a client side application where it's okay to specify a path
 	if os.IsNotExist(err) {
-		return &Config{}, nil
+		return &Config{path: path}, nil
 	} else if err != nil {
 		return nil, err
 	}
@@ -69,10 +299,219 @@ func ReadConfig(cmd *cobra.Command) (*Config, error) {
 		return nil, fmt.Errorf("unsupported configuration file version: %s", config.Version)
 	}
 
+	if config.Server != "" || config.Token != "" || config.TokenStore != "" {
+		if config.Profile(DefaultProfile) != nil {
+			return nil, fmt.Errorf("configuration file is not valid: %s: the %q profile is defined twice", path, DefaultProfile)
+		}
+		config.SetProfile(DefaultProfile, &Profile{
+			Server:     config.Server,
+			Token:      config.Token,
+			TokenStore: config.TokenStore,
+		})
+		config.Server, config.Token, config.TokenStore = "", "", ""
+	}
+
+	config.path = path
 	cachedConfig = &config
 	return &config, nil
 }
 
+// ProfileToken returns the token of the named profile, reading it from the
+// profile's token store if it is kept there. The token is empty if the
+// profile is not defined or has no token.
+func (c *Config) ProfileToken(name string) (string, error) {
+	profile := c.Profile(name)
+	if profile == nil {
+		return "", nil
+	}
+	if profile.TokenStore == "" || profile.Token != "" {
+		return profile.Token, nil
+	}
+
+	store, err := tokenstore.Open(profile.TokenStore, filepath.Dir(c.path))
+	if err != nil {
+		return "", err
+	}
+	token, err := readToken(store, c.path, name, profile)
+	if errors.Is(err, tokenstore.ErrNotFound) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("cannot read authentication token from %s token store: %w", profile.TokenStore, err)
+	}
+	profile.Token = token
+	return token, nil
+}
+
+// tokenKey returns the key of the token of the named profile in its token
+// store. Keys include the absolute path of the configuration file, so that
+// configuration files with profiles of the same name do not share tokens.
+func tokenKey(path string, name string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return name + "@" + path, nil
+}
+
+// legacyTokenKeys returns the keys that the token of the named profile was
+// stored under by earlier versions: the profile name, and before profiles,
+// the server that the default profile authenticates to.
+func legacyTokenKeys(name string, profile *Profile) []string {
+	keys := []string{name}
+	if name == DefaultProfile && profile.Server != "" {
+		keys = append(keys, profile.Server)
+	}
+	return keys
+}
+
+// readToken returns the token of the named profile from store. A token
+// stored under a legacy key is moved to the current one.
+func readToken(store tokenstore.Store, path string, name string, profile *Profile) (string, error) {
+	key, err := tokenKey(path, name)
+	if err != nil {
+		return "", err
+	}
+	token, err := store.Get(key)
+	if !errors.Is(err, tokenstore.ErrNotFound) {
+		return token, err
+	}
+
+	for _, legacyKey := range legacyTokenKeys(name, profile) {
+		token, err := store.Get(legacyKey)
+		if errors.Is(err, tokenstore.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		if err := store.Set(key, token); err != nil {
+			return "", err
+		}
+		if err := store.Delete(legacyKey); err != nil && !errors.Is(err, tokenstore.ErrNotFound) {
+			return "", err
+		}
+		return token, nil
+	}
+	return "", tokenstore.ErrNotFound
+}
+
+// WriteConfig writes config to the file system. The tokens of profiles with
+// a token store are saved there rather than in the file.
+func WriteConfig(cmd *cobra.Command, config *Config) error {
+	path, err := GetPath(cmd)
+	if err != nil {
+		return err
+	}
+
+	fileConfig := *config
+	fileConfig.Version = "1"
+	fileConfig.Profiles = nil
+	for name, profile := range config.Profiles {
+		fileProfile := *profile
+		if profile.TokenStore != "" && profile.Token != "" {
+			store, err := tokenstore.Open(profile.TokenStore, filepath.Dir(path))
+			if err != nil {
+				return err
+			}
+			key, err := tokenKey(path, name)
+			if err != nil {
+				return err
+			}
+			if err := store.Set(key, profile.Token); err != nil {
+				return fmt.Errorf("cannot save authentication token to %s token store: %w", profile.TokenStore, err)
+			}
+			fileProfile.Token = ""
+		}
+
+		if name == DefaultProfile {
+			fileConfig.Server = fileProfile.Server
+			fileConfig.Token = fileProfile.Token
+			fileConfig.TokenStore = fileProfile.TokenStore
+			continue
+		}
+		if fileConfig.Profiles == nil {
+			fileConfig.Profiles = map[string]*Profile{}
+		}
+		fileConfig.Profiles[name] = &fileProfile
+	}
+
+	configBuf, err := yaml.Marshal(fileConfig)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, configBuf, 0o600); err != nil {
+		return err
+	}
+	config.path = path
+	cachedConfig = config
+	return nil
+}
+
+// DeleteToken removes the token of the named profile from config, and from
+// the profile's token store, and writes config to the file system.
+func DeleteToken(cmd *cobra.Command, config *Config, name string) error {
+	profile := config.Profile(name)
+	if profile == nil {
+		return fmt.Errorf("profile %q is not defined", name)
+	}
+	if profile.TokenStore != "" {
+		path, err := GetPath(cmd)
+		if err != nil {
+			return err
+		}
+		store, err := tokenstore.Open(profile.TokenStore, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		key, err := tokenKey(path, name)
+		if err != nil {
+			return err
+		}
+		for _, key := range append([]string{key}, legacyTokenKeys(name, profile)...) {
+			if err := store.Delete(key); err != nil && !errors.Is(err, tokenstore.ErrNotFound) {
+				return fmt.Errorf("cannot remove authentication token from %s token store: %w", profile.TokenStore, err)
+			}
+		}
+	}
+	profile.Token = ""
+	return WriteConfig(cmd, config)
+}
+
+// ProfileName returns the name of the profile selected with --profile,
+// which defaults to $NAMETAG_PROFILE, or else the current profile.
+//
+// The flag is read from the root command, because the directory agent
+// subcommands have a --profile flag of their own that selects a directory.
+// For them, the profile can only be selected with $NAMETAG_PROFILE.
+func (c *Config) ProfileName(cmd *cobra.Command) (string, error) {
+	if flags := cmd.Root().PersistentFlags(); flags.Lookup("profile") != nil {
+		name, err := flags.GetString("profile")
+		if err != nil {
+			return "", err
+		}
+		if name != "" {
+			return name, nil
+		}
+	}
+	if c.CurrentProfile != "" {
+		return c.CurrentProfile, nil
+	}
+	return DefaultProfile, nil
+}
+
+// Profile returns the profile with the given name, or nil if there isn't
+// one.
+func (c *Config) Profile(name string) *Profile {
+	return c.Profiles[name]
+}
+
+// SetProfile adds or replaces the profile with the given name.
+func (c *Config) SetProfile(name string, profile *Profile) {
+	if c.Profiles == nil {
+		c.Profiles = map[string]*Profile{}
+	}
+	c.Profiles[name] = profile
+}
+
 // GetPath returns the config path
 func GetPath(cmd *cobra.Command) (string, error) {
 	param, err := cmd.Flags().GetString("config")
@@ -0,0 +1,367 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+
+	"github.com/nametaginc/cli/internal/tokenstore"
+)
+
+func TestPasswordGenerationYAML(t *testing.T) {
+	var ldapConfig LDAPConfig
+	if err := yaml.Unmarshal([]byte(`
+passwordGeneration:
+  minLength: 16
+  excludedCharacters: "O0"
+  humanFriendly: true
+`), &ldapConfig); err != nil {
+		t.Fatal(err)
+	}
+	want := PasswordGeneration{MinLength: 16, ExcludedCharacters: "O0", HumanFriendly: true}
+	if ldapConfig.PasswordGeneration != want {
+		t.Errorf("got %+v, want %+v", ldapConfig.PasswordGeneration, want)
+	}
+
+	buf, err := yaml.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf), "excludedCharacters: O0\nhumanFriendly: true\nminLength: 16\n"; got != want {
+		t.Errorf("marshaled %q, want %q", got, want)
+	}
+}
+
+func TestProfileYAML(t *testing.T) {
+	for _, test := range []struct {
+		value any
+		want  string
+	}{
+		{value: Profile{}, want: "{}\n"},
+		{
+			value: Profile{Server: "https://staging.example.com", Token: "abc", TokenStore: tokenstore.Keyring},
+			want:  "server: https://staging.example.com\ntoken: abc\ntokenStore: keyring\n",
+		},
+		{value: DirectoryConfig{}, want: "{}\n"},
+		{
+			value: DirectoryConfig{
+				AgentToken: "agent",
+				Okta:       &OktaConfig{URL: "https://example.okta.com", IncludeGroups: true},
+			},
+			want: "agentToken: agent\nokta:\n  includeGroups: true\n  url: https://example.okta.com\n",
+		},
+		{
+			value: DirectoryConfig{Authentik: &AuthentikConfig{URL: "https://authentik.example.com"}},
+			want:  "authentik:\n  url: https://authentik.example.com\n",
+		},
+		{
+			value: Config{Version: "1", CurrentProfile: "staging", Directories: map[string]DirectoryConfig{"corp": {AgentToken: "agent"}}},
+			want:  "LDAPConfig:\n  passwordGeneration: {}\n  schema: {}\ncurrentProfile: staging\ndirectories:\n  corp:\n    agentToken: agent\nversion: \"1\"\n",
+		},
+	} {
+		buf, err := yaml.Marshal(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf); got != test.want {
+			t.Errorf("%+v: marshaled %q, want %q", test.value, got, test.want)
+		}
+	}
+}
+
+// runCommand runs fn in a subcommand of a command with the global flags, and
+// the configuration file at path. If directoryFlag is true, the subcommand
+// has a --profile flag of its own, like the directory agent subcommands.
+func runCommand(t *testing.T, path string, directoryFlag bool, args []string, fn func(cmd *cobra.Command) error) {
+	t.Helper()
+	ClearCachedConfig()
+	t.Cleanup(ClearCachedConfig)
+
+	root := &cobra.Command{Use: "nametag", SilenceUsage: true, SilenceErrors: true}
+	root.PersistentFlags().String("config", path, "")
+	root.PersistentFlags().String("profile", os.Getenv("NAMETAG_PROFILE"), "")
+	sub := &cobra.Command{
+		Use:  "sub",
+		RunE: func(cmd *cobra.Command, args []string) error { return fn(cmd) },
+	}
+	if directoryFlag {
+		sub.Flags().String("profile", "", "")
+	}
+	root.AddCommand(sub)
+	root.SetArgs(append([]string{"sub"}, args...))
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteReadProfiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := &Config{
+		CurrentProfile: "staging",
+		Directories:    map[string]DirectoryConfig{"corp": {AgentToken: "agent"}},
+	}
+	config.SetProfile(DefaultProfile, &Profile{Token: "prod-token"})
+	config.SetProfile("staging", &Profile{Server: "https://staging.example.com", Token: "staging-token"})
+	runCommand(t, path, false, nil, func(cmd *cobra.Command) error {
+		return WriteConfig(cmd, config)
+	})
+
+	// the default profile is kept at the top level of the file
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file map[string]any
+	if err := yaml.Unmarshal(buf, &file); err != nil {
+		t.Fatal(err)
+	}
+	profiles, _ := file["profiles"].(map[string]any)
+	if file["token"] != "prod-token" || file["currentProfile"] != "staging" || profiles["staging"] == nil {
+		t.Errorf("file: got %s", buf)
+	}
+	if _, ok := profiles[DefaultProfile]; ok {
+		t.Errorf("file: the default profile is under profiles: %s", buf)
+	}
+
+	runCommand(t, path, false, nil, func(cmd *cobra.Command) error {
+		got, err := ReadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(got.Profiles, config.Profiles) {
+			t.Errorf("Profiles: got %+v, want %+v", got.Profiles, config.Profiles)
+		}
+		if got.CurrentProfile != "staging" || got.Directories["corp"].AgentToken != "agent" {
+			t.Errorf("got %+v", got)
+		}
+		if got.Server != "" || got.Token != "" {
+			t.Errorf("the default profile is also at the top level: %+v", got)
+		}
+		return nil
+	})
+}
+
+func TestReadConfigDefaultProfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("version: \"1\"\nserver: https://example.com\ntoken: abc\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	runCommand(t, path, false, nil, func(cmd *cobra.Command) error {
+		config, err := ReadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		want := &Profile{Server: "https://example.com", Token: "abc"}
+		if got := config.Profile(DefaultProfile); !reflect.DeepEqual(got, want) {
+			t.Errorf("default profile: got %+v, want %+v", got, want)
+		}
+		return nil
+	})
+
+	if err := os.WriteFile(path, []byte("version: \"1\"\ntoken: abc\nprofiles:\n  default:\n    token: def\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	runCommand(t, path, false, nil, func(cmd *cobra.Command) error {
+		if _, err := ReadConfig(cmd); err == nil || !strings.Contains(err.Error(), "defined twice") {
+			t.Errorf("got %v, want an error", err)
+		}
+		return nil
+	})
+}
+
+func TestProfileName(t *testing.T) {
+	for _, test := range []struct {
+		name          string
+		current       string
+		env           string
+		directoryFlag bool
+		args          []string
+		want          string
+	}{
+		{name: "default", want: DefaultProfile},
+		{name: "current", current: "staging", want: "staging"},
+		{name: "env", current: "staging", env: "prod", want: "prod"},
+		{name: "flag", current: "staging", env: "prod", args: []string{"--profile", "dev"}, want: "dev"},
+		{name: "directory flag", env: "prod", directoryFlag: true, args: []string{"--profile", "corp-ldap"}, want: "prod"},
+		{name: "directory flag without env", current: "staging", directoryFlag: true, args: []string{"--profile", "corp-ldap"}, want: "staging"},
+	} {
+		t.Setenv("NAMETAG_PROFILE", test.env)
+		config := &Config{CurrentProfile: test.current}
+		runCommand(t, filepath.Join(t.TempDir(), "config.yaml"), test.directoryFlag, test.args, func(cmd *cobra.Command) error {
+			got, err := config.ProfileName(cmd)
+			if err != nil {
+				return err
+			}
+			if got != test.want {
+				t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+			}
+			return nil
+		})
+	}
+}
+
+func TestTokenStoreProfiles(t *testing.T) {
+	t.Setenv("NAMETAG_TOKEN_STORE_PASSPHRASE", "passphrase")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	config := &Config{}
+	config.SetProfile(DefaultProfile, &Profile{Token: "prod-token", TokenStore: tokenstore.File})
+	config.SetProfile("staging", &Profile{Server: "https://staging.example.com", Token: "staging-token", TokenStore: tokenstore.File})
+	runCommand(t, path, false, nil, func(cmd *cobra.Command) error {
+		return WriteConfig(cmd, config)
+	})
+
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(buf), "-token") {
+		t.Errorf("the tokens are in the configuration file: %s", buf)
+	}
+
+	for name, want := range map[string]string{DefaultProfile: "prod-token", "staging": "staging-token"} {
+		runCommand(t, path, false, []string{"--profile", name}, func(cmd *cobra.Command) error {
+			config, err := ReadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			got, err := config.ProfileToken(name)
+			if err != nil {
+				return err
+			}
+			if got != want {
+				t.Errorf("%s: got token %q, want %q", name, got, want)
+			}
+			return nil
+		})
+	}
+}
+
+func TestReadConfigWithoutPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	config := &Config{}
+	config.SetProfile(DefaultProfile, &Profile{Server: "https://example.com", Token: "prod-token", TokenStore: tokenstore.File})
+	t.Setenv("NAMETAG_TOKEN_STORE_PASSPHRASE", "passphrase")
+	runCommand(t, path, false, nil, func(cmd *cobra.Command) error {
+		return WriteConfig(cmd, config)
+	})
+
+	// commands that do not need the token work without the passphrase
+	t.Setenv("NAMETAG_TOKEN_STORE_PASSPHRASE", "")
+	runCommand(t, path, false, nil, func(cmd *cobra.Command) error {
+		config, err := ReadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		if got := config.Profile(DefaultProfile).Server; got != "https://example.com" {
+			t.Errorf("server: got %q, want %q", got, "https://example.com")
+		}
+		if _, err := config.ProfileToken(DefaultProfile); err == nil {
+			t.Errorf("ProfileToken: got no error without a passphrase")
+		}
+		return nil
+	})
+}
+
+func TestTokenStoreMigration(t *testing.T) {
+	// tokens used to be stored under the profile name, and before profiles,
+	// under the server
+	for _, legacyKey := range []string{DefaultProfile, "https://example.com"} {
+		t.Setenv("NAMETAG_TOKEN_STORE_PASSPHRASE", "passphrase")
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.yaml")
+		if err := os.WriteFile(path, []byte("version: \"1\"\nserver: https://example.com\ntokenStore: file\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		store, err := tokenstore.Open(tokenstore.File, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Set(legacyKey, "old-token"); err != nil {
+			t.Fatal(err)
+		}
+
+		runCommand(t, path, false, nil, func(cmd *cobra.Command) error {
+			config, err := ReadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			got, err := config.ProfileToken(DefaultProfile)
+			if err != nil {
+				return err
+			}
+			if got != "old-token" {
+				t.Errorf("%s: got token %q, want %q", legacyKey, got, "old-token")
+			}
+			return nil
+		})
+
+		key, err := tokenKey(path, DefaultProfile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := store.Get(key); err != nil || got != "old-token" {
+			t.Errorf("%s: Get(%q): got %q, %v, want %q", legacyKey, key, got, err, "old-token")
+		}
+		if _, err := store.Get(legacyKey); !errors.Is(err, tokenstore.ErrNotFound) {
+			t.Errorf("%s: Get: got %v, want %v", legacyKey, err, tokenstore.ErrNotFound)
+		}
+	}
+}
+
+func TestTokenStoreConfigFiles(t *testing.T) {
+	t.Setenv("NAMETAG_TOKEN_STORE_PASSPHRASE", "passphrase")
+	dir := t.TempDir()
+
+	// configuration files in the same directory share the file token store
+	paths := map[string]string{
+		filepath.Join(dir, "a.yaml"): "a-token",
+		filepath.Join(dir, "b.yaml"): "b-token",
+	}
+	for path, token := range paths {
+		config := &Config{}
+		config.SetProfile(DefaultProfile, &Profile{Token: token, TokenStore: tokenstore.File})
+		runCommand(t, path, false, nil, func(cmd *cobra.Command) error {
+			return WriteConfig(cmd, config)
+		})
+	}
+
+	for path, want := range paths {
+		runCommand(t, path, false, nil, func(cmd *cobra.Command) error {
+			config, err := ReadConfig(cmd)
+			if err != nil {
+				return err
+			}
+			got, err := config.ProfileToken(DefaultProfile)
+			if err != nil {
+				return err
+			}
+			if got != want {
+				t.Errorf("%s: got token %q, want %q", path, got, want)
+			}
+			return nil
+		})
+	}
+}
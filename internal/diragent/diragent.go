@@ -57,6 +57,11 @@ type Service struct {
 	cmdStdout        io.ReadCloser
 	cmdStdinEncoder  *json.Encoder
 	cmdStdoutDecoder *json.Decoder
+
+	// RefreshToken, if set, is called when the server rejects AuthToken. It
+	// may update AuthToken, e.g. by resolving a secret reference again, and
+	// returns true if it did.
+	RefreshToken func() (bool, error)
 }
 
 // Run runs the directory agent service. It connects to the server
@@ -145,6 +150,22 @@ func (s *Service) Run(ctx context.Context) error {
 	}
 }
 
+// refreshToken picks up a rotated auth token, which is used the next
+// time the service connects.
+func (s *Service) refreshToken() {
+	if s.RefreshToken == nil {
+		return
+	}
+	changed, err := s.RefreshToken()
+	if err != nil {
+		log.Printf("ERROR: cannot refresh agent token: %s", err)
+		return
+	}
+	if changed {
+		log.Printf("agent token was rejected and has changed, reconnecting with the new token")
+	}
+}
+
 func (s *Service) runOnce(ctx context.Context) error {
 	ctx, cancel := context.WithCancelCause(ctx)
 	defer cancel(nil)
@@ -164,6 +185,9 @@ func (s *Service) runOnce(ctx context.Context) error {
 	redactedConnectURL.RawQuery = redactedConnectQuery.Encode()
 
 	conn, wsResp, err := websocket.Dial(ctx, connectURL.String(), &websocket.DialOptions{HTTPClient: s.HTTPClient})
+	if wsResp != nil && wsResp.StatusCode == http.StatusUnauthorized {
+		s.refreshToken()
+	}
 	if err != nil {
 		return fmt.Errorf("cannot connect to server %q: %w", redactedConnectURL.String(), err)
 	}
@@ -0,0 +1,140 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package secret resolves references to secrets in configuration values and
+// flags, so that secrets don't have to be stored in plaintext.
+//
+// A reference is one of:
+//
+//	env:NAME      the value of the environment variable NAME
+//	file:PATH     the contents of the file at PATH
+//	exec:COMMAND  the output of COMMAND, run with the system shell
+//
+// Trailing newlines are removed from file contents and command output. Any
+// other value is used as is.
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+const (
+	envPrefix  = "env:"
+	filePrefix = "file:"
+	execPrefix = "exec:"
+)
+
+// IsReference returns true if value is a secret reference.
+func IsReference(value string) bool {
+	return strings.HasPrefix(value, envPrefix) ||
+		strings.HasPrefix(value, filePrefix) ||
+		strings.HasPrefix(value, execPrefix)
+}
+
+// Resolve returns the secret that ref refers to, or ref itself if it is not
+// a reference.
+func Resolve(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, envPrefix):
+		name := strings.TrimPrefix(ref, envPrefix)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("cannot resolve secret %q: environment variable is not set", ref)
+		}
+		return value, nil
+
+	case strings.HasPrefix(ref, filePrefix):
+		path := strings.TrimPrefix(ref, filePrefix)
+		buf, err := os.ReadFile(path) // #nosec G304
+		if err != nil {
+			return "", fmt.Errorf("cannot resolve secret %q: %w", ref, err)
+		}
+		return strings.TrimRight(string(buf), "\r\n"), nil
+
+	case strings.HasPrefix(ref, execPrefix):
+		command := strings.TrimPrefix(ref, execPrefix)
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/c", command) //nolint:gosec
+		} else {
+			cmd = exec.Command("/bin/sh", "-c", command) //nolint:gosec
+		}
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("cannot resolve secret %q: %w: %s", ref, err, strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+
+	default:
+		return ref, nil
+	}
+}
+
+// Set is a set of values resolved from references, which can be resolved
+// again when a credential is rejected, to pick up rotated secrets.
+type Set struct {
+	mu      sync.Mutex
+	entries []entry
+}
+
+type entry struct {
+	ref string
+	dst *string
+}
+
+// Add resolves the reference in *dst, replacing it with the secret. If *dst
+// is not a reference, it is left alone.
+func (s *Set) Add(dst *string) error {
+	if !IsReference(*dst) {
+		return nil
+	}
+	value, err := Resolve(*dst)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, entry{ref: *dst, dst: dst})
+	*dst = value
+	return nil
+}
+
+// Refresh resolves every reference again, and returns true if any of the
+// secrets changed.
+func (s *Set) Refresh() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for _, e := range s.entries {
+		value, err := Resolve(e.ref)
+		if err != nil {
+			return changed, err
+		}
+		if value != *e.dst {
+			*e.dst = value
+			changed = true
+		}
+	}
+	return changed, nil
+}
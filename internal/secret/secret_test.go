@@ -0,0 +1,95 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret")
+	if err := os.WriteFile(path, []byte("from file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECRET_TEST_VALUE", "from env")
+
+	for _, test := range []struct {
+		ref     string
+		want    string
+		wantErr string
+		unix    bool
+	}{
+		{ref: "plain", want: "plain"},
+		{ref: "", want: ""},
+		{ref: "ENV:SECRET_TEST_VALUE", want: "ENV:SECRET_TEST_VALUE"},
+		{ref: "env:SECRET_TEST_VALUE", want: "from env"},
+		{ref: "env:SECRET_TEST_MISSING", wantErr: "environment variable is not set"},
+		{ref: "file:" + path, want: "from file"},
+		{ref: "file:" + filepath.Join(dir, "missing"), wantErr: "cannot resolve secret"},
+		{ref: "exec:printf 'from exec\\r\\n\\n'", want: "from exec", unix: true},
+		{ref: "exec:echo oops >&2; exit 3", wantErr: "oops", unix: true},
+	} {
+		if test.unix && runtime.GOOS == "windows" {
+			continue
+		}
+		got, err := Resolve(test.ref)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%q: got error %v, want %q", test.ref, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.ref, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: got %q, want %q", test.ref, got, test.want)
+		}
+	}
+}
+
+func TestSetRefresh(t *testing.T) {
+	t.Setenv("SECRET_TEST_VALUE", "one")
+
+	var set Set
+	value, plain := "env:SECRET_TEST_VALUE", "plain"
+	if err := set.Add(&value); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Add(&plain); err != nil {
+		t.Fatal(err)
+	}
+	if value != "one" || plain != "plain" {
+		t.Fatalf("Add: got %q, %q", value, plain)
+	}
+
+	if changed, err := set.Refresh(); err != nil || changed {
+		t.Errorf("Refresh: got %v, %v, want false, nil", changed, err)
+	}
+
+	t.Setenv("SECRET_TEST_VALUE", "two")
+	if changed, err := set.Refresh(); err != nil || !changed {
+		t.Errorf("Refresh: got %v, %v, want true, nil", changed, err)
+	}
+	if value != "two" {
+		t.Errorf("value: got %q, want %q", value, "two")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tokenstore
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// passphraseEnv is the environment variable holding the passphrase that the
+// file store key is derived from. The file store cannot be used without it:
+// a key kept on the same disk as the tokens would not protect them.
+const passphraseEnv = "NAMETAG_TOKEN_STORE_PASSPHRASE" // #nosec G101
+
+const (
+	fileStoreName = "tokens.enc"
+
+	saltLen  = 16
+	nonceLen = 24
+	keyLen   = 32
+)
+
+// fileStore keeps tokens in a file encrypted with NaCl secretbox. The file
+// holds a salt, a nonce and the sealed JSON object of tokens by key.
+type fileStore struct {
+	path string
+}
+
+func newFileStore(dir string) *fileStore {
+	return &fileStore{path: filepath.Join(dir, fileStoreName)}
+}
+
+func (s *fileStore) Get(key string) (string, error) {
+	tokens, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	token, ok := tokens[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return token, nil
+}
+
+func (s *fileStore) Set(key string, token string) error {
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+	tokens[key] = token
+	return s.write(tokens)
+}
+
+func (s *fileStore) Delete(key string) error {
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := tokens[key]; !ok {
+		return ErrNotFound
+	}
+	delete(tokens, key)
+	return s.write(tokens)
+}
+
+func (s *fileStore) read() (map[string]string, error) {
+	buf, err := os.ReadFile(s.path) // #nosec G304
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < saltLen+nonceLen+secretbox.Overhead {
+		return nil, fmt.Errorf("token store %s is corrupt", s.path)
+	}
+
+	salt := buf[:saltLen]
+	var nonce [nonceLen]byte
+	copy(nonce[:], buf[saltLen:saltLen+nonceLen])
+	key, err := s.key(salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, ok := secretbox.Open(nil, buf[saltLen+nonceLen:], &nonce, key)
+	if !ok {
+		return nil, fmt.Errorf("cannot decrypt token store %s: wrong passphrase ($%s)", s.path, passphraseEnv)
+	}
+
+	tokens := map[string]string{}
+	if err := json.Unmarshal(plaintext, &tokens); err != nil {
+		return nil, fmt.Errorf("token store %s is corrupt: %w", s.path, err)
+	}
+	return tokens, nil
+}
+
+func (s *fileStore) write(tokens map[string]string) error {
+	plaintext, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, saltLen+nonceLen, saltLen+nonceLen+len(plaintext)+secretbox.Overhead)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	var nonce [nonceLen]byte
+	copy(nonce[:], buf[saltLen:])
+	key, err := s.key(buf[:saltLen])
+	if err != nil {
+		return err
+	}
+	buf = secretbox.Seal(buf, plaintext, &nonce, key)
+
+	// write to a temporary file first so that the store is never left
+	// partly written
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), fileStoreName+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(buf); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// key derives the encryption key from the passphrase.
+func (s *fileStore) key(salt []byte) (*[keyLen]byte, error) {
+	passphrase := os.Getenv(passphraseEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("cannot use token store %s: $%s is not set", s.path, passphraseEnv)
+	}
+
+	var key [keyLen]byte
+	copy(key[:], argon2.IDKey([]byte(passphrase), salt, 1, 64*1024, 4, keyLen))
+	return &key, nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tokenstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	t.Setenv(passphraseEnv, "correct horse battery staple")
+	dir := t.TempDir()
+	store, err := Open(File, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.Get("default"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get from an empty store: got %v, want %v", err, ErrNotFound)
+	}
+	if err := store.Set("default", "token-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("staging", "token-2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// a new store reads what the first one wrote
+	store = newFileStore(dir)
+	for key, want := range map[string]string{"default": "token-1", "staging": "token-2"} {
+		got, err := store.Get(key)
+		if err != nil {
+			t.Errorf("Get(%q): %v", key, err)
+		} else if got != want {
+			t.Errorf("Get(%q): got %q, want %q", key, got, want)
+		}
+	}
+
+	buf, err := os.ReadFile(filepath.Join(dir, fileStoreName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(buf), "token-1") {
+		t.Errorf("the token is stored in plaintext")
+	}
+
+	if err := store.Delete("default"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("default"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: got %v, want %v", err, ErrNotFound)
+	}
+	if err := store.Delete("default"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete twice: got %v, want %v", err, ErrNotFound)
+	}
+	if got, err := store.Get("staging"); err != nil || got != "token-2" {
+		t.Errorf("Get(%q) after Delete: got %q, %v, want %q", "staging", got, err, "token-2")
+	}
+}
+
+func TestFileStoreWrongPassphrase(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(passphraseEnv, "right")
+	if err := newFileStore(dir).Set("default", "token"); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(passphraseEnv, "wrong")
+	store := newFileStore(dir)
+	if _, err := store.Get("default"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("Get: got %v, want a wrong passphrase error", err)
+	}
+	// the store is not overwritten with the wrong passphrase
+	if err := store.Set("default", "other"); err == nil {
+		t.Errorf("Set: got no error")
+	}
+
+	t.Setenv(passphraseEnv, "")
+	if _, err := store.Get("default"); err == nil || !strings.Contains(err.Error(), passphraseEnv) {
+		t.Errorf("Get without a passphrase: got %v, want an error naming $%s", err, passphraseEnv)
+	}
+
+	t.Setenv(passphraseEnv, "right")
+	if got, err := store.Get("default"); err != nil || got != "token" {
+		t.Errorf("Get: got %q, %v, want %q", got, err, "token")
+	}
+}
+
+func TestFileStoreCorrupt(t *testing.T) {
+	t.Setenv(passphraseEnv, "passphrase")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, fileStoreName), []byte("short"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newFileStore(dir).Get("default"); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Errorf("Get: got %v, want a corrupt store error", err)
+	}
+}
+
+func TestSelectFile(t *testing.T) {
+	t.Setenv(passphraseEnv, "")
+	if _, err := Select(File); err == nil {
+		t.Errorf("Select(%q) without a passphrase: got no error", File)
+	}
+
+	t.Setenv(passphraseEnv, "passphrase")
+	if got, err := Select(File); err != nil || got != File {
+		t.Errorf("Select(%q): got %q, %v, want %q", File, got, err, File)
+	}
+	if got, err := Select(Config); err != nil || got != Config {
+		t.Errorf("Select(%q): got %q, %v, want %q", Config, got, err, Config)
+	}
+	if _, err := Select("plaintext"); err == nil {
+		t.Errorf("Select(%q): got no error", "plaintext")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2026 Nametag Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tokenstore keeps Nametag authentication tokens out of the
+// configuration file, in the OS credential store or in a file encrypted with
+// a passphrase.
+package tokenstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Backends that can be selected with --token-store.
+const (
+	// Auto uses the OS credential store if it is available, or else an
+	// encrypted file if $NAMETAG_TOKEN_STORE_PASSPHRASE is set, or else the
+	// configuration file.
+	Auto = "auto"
+
+	// Keyring uses the OS credential store: the Secret Service (e.g. GNOME
+	// Keyring or KWallet) on Linux, the Keychain on macOS and the Credential
+	// Manager on Windows.
+	Keyring = "keyring"
+
+	// File uses a file encrypted with a key derived from
+	// $NAMETAG_TOKEN_STORE_PASSPHRASE, which must be set whenever the token
+	// is used. It works without a desktop session, e.g. on servers.
+	File = "file"
+
+	// Config keeps the token in plaintext in the configuration file.
+	Config = "config"
+)
+
+// ErrNotFound is returned by Store.Get when there is no token for a key.
+var ErrNotFound = errors.New("token not found")
+
+// Store holds tokens by key.
+type Store interface {
+	Get(key string) (string, error)
+	Set(key string, token string) error
+	Delete(key string) error
+}
+
+// Select returns the backend to use for the requested one, resolving Auto
+// to Keyring, File or Config.
+func Select(backend string) (string, error) {
+	switch backend {
+	case "", Auto:
+		if keyringAvailable() {
+			return Keyring, nil
+		}
+		if os.Getenv(passphraseEnv) != "" {
+			return File, nil
+		}
+		return Config, nil
+	case Keyring:
+		if !keyringAvailable() {
+			return "", fmt.Errorf("the OS credential store is not available, set $%s and use --token-store=%s instead", passphraseEnv, File)
+		}
+		return Keyring, nil
+	case File:
+		if os.Getenv(passphraseEnv) == "" {
+			return "", fmt.Errorf("the %s token store needs a passphrase in $%s", File, passphraseEnv)
+		}
+		return File, nil
+	case Config:
+		return Config, nil
+	default:
+		return "", fmt.Errorf("unknown token store %q, expected %s, %s, %s or %s", backend, Auto, Keyring, File, Config)
+	}
+}
+
+// Open returns the store for backend, which must be Keyring or File. dir
+// is the directory of the configuration file.
+func Open(backend string, dir string) (Store, error) {
+	switch backend {
+	case Keyring:
+		return keyringStore{}, nil
+	case File:
+		return newFileStore(dir), nil
+	default:
+		return nil, fmt.Errorf("unknown token store %q", backend)
+	}
+}